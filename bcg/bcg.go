@@ -10,9 +10,10 @@ import (
 	"time"
 )
 
+// CheckError 输出错误并返回 true，err 为 nil 时返回 false。如果错误链中有 Error，会输出错误码、附加字段和错误创建的位置
 func CheckError(err error) bool {
 	if err != nil {
-		LogTrace(TextRed, 1, err.Error())
+		LogTrace(TextRed, 1, errorDetail(err))
 		return true
 	}
 	return false
@@ -22,7 +23,7 @@ func CheckError(err error) bool {
 //trace = 1 记录使用这个函数的位置，trace = 2 记录上一级
 func CheckErrTrace(err error, trace uint) bool {
 	if err != nil {
-		LogTrace(TextRed, trace, err.Error())
+		LogTrace(TextRed, trace, errorDetail(err))
 		return true
 	}
 	return false
@@ -0,0 +1,453 @@
+package bcg
+
+// config 分层加载配置到结构体，后面的层覆盖前面的层：
+//  1. 结构体 tag 中的默认值，比如 `default:"8080"`
+//  2. 一个或多个 json 文件，按顺序合并，文件中可以有 // 和 /* */ 注释，以及对象和数组末尾多余的逗号
+//  3. 环境变量，名字是 EnvPrefix 加上大写的 key，点换成下划线，比如 GAME_DB_HOST，也可以用 `env:"NAME"` 指定
+//  4. 命令行参数，比如 -db.host=127.0.0.1 或者 --debug，也可以用 `flag:"name"` 指定参数名，`desc:"..."` 是参数说明
+//
+// 配置的 key 由 json tag 的名字组成，嵌套的结构体用点连接，和 GetPath 的路径格式相同。
+// 加载失败时返回的错误会指明是哪一层、哪个文件的哪一行或者哪个 key 出错，Source 返回每个 key 最终的值来自哪里。
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ConfigSourceDefault = "default"
+	ConfigSourceFile    = "file"
+	ConfigSourceEnv     = "env"
+	ConfigSourceFlag    = "flag"
+)
+
+type ConfigLoader struct {
+	Files     []string // 按顺序合并的 json 文件
+	EnvPrefix string   // 环境变量的前缀，为空时只读取 env tag 指定的环境变量
+	Args      []string // 命令行参数，一般是 os.Args[1:]，为 nil 时不解析命令行
+	// IgnoreMissingFiles 为 true 时不存在的文件会被跳过，否则返回错误
+	IgnoreMissingFiles bool
+
+	sources map[string]string
+	rest    []string
+}
+
+// configField 配置结构体中的一个叶子字段
+type configField struct {
+	key   string
+	value reflect.Value
+	field reflect.StructField
+}
+
+func NewConfigLoader(files ...string) *ConfigLoader {
+	return &ConfigLoader{Files: files}
+}
+
+// Load 加载配置，conf 必须是结构体指针
+func (l *ConfigLoader) Load(conf interface{}) error {
+	rv := reflect.ValueOf(conf)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("config: conf must be a pointer to struct")
+	}
+	l.sources = map[string]string{}
+	l.rest = nil
+
+	fields := configFields(rv.Elem(), "")
+	for _, f := range fields {
+		if def, ok := f.field.Tag.Lookup("default"); ok {
+			if err := setFieldString(f.value, def); err != nil {
+				return fmt.Errorf("config default %s: %v", f.key, err)
+			}
+			l.sources[f.key] = ConfigSourceDefault
+		}
+	}
+	for _, fn := range l.Files {
+		if err := l.loadFile(fn, conf, fields); err != nil {
+			return err
+		}
+	}
+	// 文件可能替换了嵌套结构体的指针，重新获取字段
+	fields = configFields(rv.Elem(), "")
+	if err := l.loadEnv(fields); err != nil {
+		return err
+	}
+	return l.loadFlags(fields)
+}
+
+// Source 返回 key 最终的值来自哪里，比如 "default"、"file:conf.json"、"env:GAME_DB_HOST"、"flag:-db.host"，
+// 没有被任何一层设置时返回空字串
+func (l *ConfigLoader) Source(key string) string {
+	return l.sources[key]
+}
+
+// Sources 返回所有被设置过的 key 和它们的来源
+func (l *ConfigLoader) Sources() map[string]string {
+	m := make(map[string]string, len(l.sources))
+	for k, v := range l.sources {
+		m[k] = v
+	}
+	return m
+}
+
+// RestArgs 返回命令行参数中不是配置项的部分
+func (l *ConfigLoader) RestArgs() []string {
+	return l.rest
+}
+
+// FormatSources 返回每个 key 和来源的文本，每行一个，用于启动时打印配置的来源
+func (l *ConfigLoader) FormatSources() string {
+	keys := make([]string, 0, len(l.sources))
+	for k := range l.sources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k + " = " + l.sources[k] + "\n")
+	}
+	return sb.String()
+}
+
+func configFields(v reflect.Value, prefix string) []configField {
+	fields := make([]configField, 0)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		name := sf.Name
+		if tag := sf.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			if n := strings.Split(tag, ",")[0]; n != "" {
+				name = n
+			}
+		}
+		fv := v.Field(i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct && !isConfigLeaf(ft) {
+			// 未导出的嵌入指针不能设置，和 encoding/json 一样忽略
+			if sf.PkgPath != "" {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(ft.Elem()))
+			}
+			fv, ft = fv.Elem(), ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !isConfigLeaf(ft) {
+			if sf.Anonymous && sf.Tag.Get("json") == "" {
+				fields = append(fields, configFields(fv, prefix)...)
+			} else {
+				fields = append(fields, configFields(fv, prefix+name+".")...)
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		fields = append(fields, configField{key: prefix + name, value: fv, field: sf})
+	}
+	return fields
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isConfigLeaf time.Time 这样实现了 TextUnmarshaler 的结构体作为一个值处理，而不是展开它的字段
+func isConfigLeaf(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(textUnmarshalerType) || t.Implements(textUnmarshalerType)
+}
+
+// setFieldString 把字串转换为字段的类型，基本类型直接转换，time.Duration 使用 time.ParseDuration，
+// 实现了 TextUnmarshaler 的类型使用 UnmarshalText，其它类型（slice、map 等）按 json 解析
+func setFieldString(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if v.CanAddr() {
+		if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText([]byte(s))
+		}
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", v.Type(), s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", v.Type(), s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", v.Type(), s)
+		}
+		v.SetFloat(f)
+	default:
+		p := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(s), p.Interface()); err != nil {
+			return fmt.Errorf("invalid %s %q: %v", v.Type(), s, err)
+		}
+		v.Set(p.Elem())
+	}
+	return nil
+}
+
+func (l *ConfigLoader) loadFile(fn string, conf interface{}, fields []configField) error {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		if l.IgnoreMissingFiles && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("config file %s: %v", fn, err)
+	}
+	data = stripJsonComments(data)
+	if err = json.Unmarshal(data, conf); err != nil {
+		return fmt.Errorf("config file %s%s: %v", fn, jsonErrorPosition(data, err), err)
+	}
+	var doc map[string]interface{}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("config file %s%s: %v", fn, jsonErrorPosition(data, err), err)
+	}
+	keys := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		keys[f.key] = true
+	}
+	l.recordFileSources(doc, "", keys, ConfigSourceFile+":"+fn)
+	return nil
+}
+
+// recordFileSources 记录文件中出现的 key，文件中的 key 和结构体字段的匹配不区分大小写，和 json.Unmarshal 相同
+func (l *ConfigLoader) recordFileSources(doc map[string]interface{}, prefix string, keys map[string]bool, source string) {
+	for k, v := range doc {
+		key := prefix + k
+		if !keys[key] {
+			for fk := range keys {
+				if strings.EqualFold(fk, key) {
+					key = fk
+					break
+				}
+			}
+		}
+		if keys[key] {
+			l.sources[key] = source
+		} else if m, ok := v.(map[string]interface{}); ok {
+			l.recordFileSources(m, key+".", keys, source)
+		}
+	}
+}
+
+// jsonErrorPosition 返回 json 错误所在的行和列，比如 " line 3:12"
+func jsonErrorPosition(data []byte, err error) string {
+	offset := errorOffset(err, -1)
+	if offset < 0 || offset > int64(len(data)) {
+		return ""
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+	return fmt.Sprintf(" line %d:%d", line, col)
+}
+
+// stripJsonComments 把注释和末尾多余的逗号替换为空格，保留换行，所以错误位置的行号和原文件相同
+func stripJsonComments(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	inString := false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			stop := len(out)
+			if end != -1 {
+				stop = i + 2 + end + 2
+			}
+			for ; i < stop; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			i--
+		}
+	}
+	// 第二遍去掉 } 和 ] 前面的逗号，此时注释已经是空格
+	inString = false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+		} else if c == ',' {
+			j := i + 1
+			for j < len(out) && strings.IndexByte(" \t\r\n", out[j]) != -1 {
+				j++
+			}
+			if j < len(out) && (out[j] == '}' || out[j] == ']') {
+				out[i] = ' '
+			}
+		}
+	}
+	return out
+}
+
+// envName 返回字段对应的环境变量名，没有时返回空字串
+func (l *ConfigLoader) envName(f configField) string {
+	if name, ok := f.field.Tag.Lookup("env"); ok {
+		return name
+	}
+	if l.EnvPrefix == "" {
+		return ""
+	}
+	return strings.ToUpper(l.EnvPrefix + "_" + strings.NewReplacer(".", "_", "-", "_").Replace(f.key))
+}
+
+func (l *ConfigLoader) loadEnv(fields []configField) error {
+	for _, f := range fields {
+		name := l.envName(f)
+		if name == "" {
+			continue
+		}
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setFieldString(f.value, val); err != nil {
+			return fmt.Errorf("config env %s (%s): %v", name, f.key, err)
+		}
+		l.sources[f.key] = ConfigSourceEnv + ":" + name
+	}
+	return nil
+}
+
+// configFlag 把配置字段包装为 flag.Value
+type configFlag struct {
+	l *ConfigLoader
+	f configField
+}
+
+func (cf *configFlag) String() string {
+	if cf == nil || !cf.f.value.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(cf.f.value.Interface())
+}
+func (cf *configFlag) Set(s string) error {
+	if err := setFieldString(cf.f.value, s); err != nil {
+		return err
+	}
+	cf.l.sources[cf.f.key] = ConfigSourceFlag + ":-" + flagName(cf.f)
+	return nil
+}
+func (cf *configFlag) IsBoolFlag() bool {
+	return cf.f.value.Kind() == reflect.Bool
+}
+
+func flagName(f configField) string {
+	if name := f.field.Tag.Get("flag"); name != "" {
+		return name
+	}
+	return f.key
+}
+
+func (l *ConfigLoader) loadFlags(fields []configField) error {
+	if l.Args == nil {
+		return nil
+	}
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	for _, f := range fields {
+		name := flagName(f)
+		if prev := fs.Lookup(name); prev != nil {
+			return fmt.Errorf("config flag: -%s is defined by both %s and %s", name, prev.Value.(*configFlag).f.key, f.key)
+		}
+		fs.Var(&configFlag{l: l, f: f}, name, f.field.Tag.Get("desc"))
+	}
+	if err := fs.Parse(l.Args); err != nil {
+		return fmt.Errorf("config flag: %v", err)
+	}
+	l.rest = fs.Args()
+	return nil
+}
+
+// ConfigUsage 返回配置结构体的命令行参数说明，包括参数名、环境变量、默认值和 desc tag。
+// 只使用 conf 的类型，不会修改它，所以也可以传入 nil 的结构体指针
+func (l *ConfigLoader) ConfigUsage(conf interface{}) string {
+	t := reflect.TypeOf(conf)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return ""
+	}
+	// configFields 会为 nil 的嵌套结构体指针分配内存，所以在新的零值上遍历
+	var sb strings.Builder
+	for _, f := range configFields(reflect.New(t.Elem()).Elem(), "") {
+		sb.WriteString("  -" + flagName(f))
+		if name := l.envName(f); name != "" {
+			sb.WriteString(" ($" + name + ")")
+		}
+		if def, ok := f.field.Tag.Lookup("default"); ok {
+			sb.WriteString(" default " + def)
+		}
+		if desc := f.field.Tag.Get("desc"); desc != "" {
+			sb.WriteString("\n    \t" + desc)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
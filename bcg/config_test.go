@@ -0,0 +1,30 @@
+package bcg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigUsageKeepsConf(t *testing.T) {
+	type dbConf struct {
+		Host string `json:"host" default:"127.0.0.1" desc:"数据库地址"`
+	}
+	type conf struct {
+		Port int     `json:"port" default:"80"`
+		Db   *dbConf `json:"db"`
+	}
+	l := NewConfigLoader()
+	c := &conf{}
+	usage := l.ConfigUsage(c)
+	if c.Db != nil {
+		t.Errorf("ConfigUsage allocated the nested pointer in conf")
+	}
+	for _, want := range []string{"-port default 80", "-db.host default 127.0.0.1", "数据库地址"} {
+		if !strings.Contains(usage, want) {
+			t.Errorf("usage missing %q:\n%s", want, usage)
+		}
+	}
+	if got := l.ConfigUsage((*conf)(nil)); got != usage {
+		t.Errorf("nil pointer usage:\n%s\nwant:\n%s", got, usage)
+	}
+}
@@ -0,0 +1,204 @@
+package bcg
+
+// config_watch 监视配置文件，文件改变后自动重新加载，修改游戏数值不需要重启服务。
+// 使用轮询检查文件的修改时间和大小，变化后再比较内容的 sha256，所以在任何文件系统上都可以工作，
+// 只是修改文件后要等一个轮询周期才会生效。
+// 新配置加载或者校验失败时保留原来的配置并输出错误；成功时原子地替换配置，并按注册顺序调用回调函数。
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConfigWatcher 监视配置文件，T 必须是结构体类型，配置的加载规则和 ConfigLoader 相同
+type ConfigWatcher[T any] struct {
+	// Loader 用于加载配置，可以在 Start 之前设置 EnvPrefix 等字段，Args 不应该设置，因为命令行参数不会改变
+	Loader   *ConfigLoader
+	validate func(*T) error
+	value    atomic.Value
+	// reloadMu 保证同时只有一个 Reload 在执行，旧的配置不会覆盖新的配置
+	reloadMu sync.Mutex
+
+	mu        sync.Mutex
+	callbacks []func(old, new *T)
+	stats     map[string]configFileStat
+	lastErr   error
+	stop      chan struct{}
+}
+
+type configFileStat struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+	// missing 文件已被删除，删除只报告一次，重新创建时再报告变化
+	missing bool
+}
+
+// NewConfigWatcher 加载配置文件并返回监视器，第一次加载失败时返回错误。
+// validate 用于检查配置的语义，比如数值范围，返回错误时新配置不会生效，可以为 nil
+func NewConfigWatcher[T any](validate func(*T) error, files ...string) (*ConfigWatcher[T], error) {
+	w := &ConfigWatcher[T]{
+		Loader:   NewConfigLoader(files...),
+		validate: validate,
+		stats:    map[string]configFileStat{},
+	}
+	if _, err := w.Reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Get 返回当前的配置，返回的配置不应该被修改，因为它可能同时被其它 goroutine 读取
+func (w *ConfigWatcher[T]) Get() *T {
+	return w.value.Load().(*T)
+}
+
+// OnChange 注册配置改变时的回调函数，old 和 new 分别是原来的配置和新配置
+func (w *ConfigWatcher[T]) OnChange(cb func(old, new *T)) {
+	w.mu.Lock()
+	w.callbacks = append(w.callbacks, cb)
+	w.mu.Unlock()
+}
+
+// LastError 返回最近一次重新加载的错误，成功时为 nil
+func (w *ConfigWatcher[T]) LastError() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
+
+// Start 开始每隔 interval 检查一次文件，重复调用不会启动多个 goroutine
+func (w *ConfigWatcher[T]) Start(interval time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+	stop := w.stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.Check()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (w *ConfigWatcher[T]) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+}
+
+// Check 检查文件是否改变，改变时重新加载，返回配置是否被替换
+func (w *ConfigWatcher[T]) Check() bool {
+	if !w.changed() {
+		return false
+	}
+	ok, err := w.Reload()
+	if err != nil {
+		LogTrace(TextRed, 1, "reload config failed, keep the previous config:", err.Error())
+	}
+	return ok
+}
+
+// changed 比较文件的修改时间和大小，有变化时再比较内容的哈希值，文件被删除也算变化，但只报告一次
+func (w *ConfigWatcher[T]) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := false
+	for _, fn := range w.Loader.Files {
+		old, known := w.stats[fn]
+		fi, err := os.Stat(fn)
+		if err != nil {
+			if known && !old.missing {
+				changed = true
+				w.stats[fn] = configFileStat{missing: true}
+			}
+			continue
+		}
+		if known && !old.missing && fi.ModTime().Equal(old.modTime) && fi.Size() == old.size {
+			continue
+		}
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			changed = true
+			continue
+		}
+		st := configFileStat{modTime: fi.ModTime(), size: fi.Size(), hash: sha256.Sum256(data)}
+		if !known || old.missing || st.hash != old.hash {
+			changed = true
+		}
+		w.stats[fn] = st
+	}
+	return changed
+}
+
+// Reload 立即重新加载配置，不管文件是否改变。加载或者校验失败时返回错误，并保留原来的配置。
+// 多次调用按顺序执行，回调函数返回后才会开始下一次加载，所以回调函数中不能调用 Reload 和 Check
+func (w *ConfigWatcher[T]) Reload() (bool, error) {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+	stats := map[string]configFileStat{}
+	for _, fn := range w.Loader.Files {
+		if fi, err := os.Stat(fn); err == nil {
+			if data, err := os.ReadFile(fn); err == nil {
+				stats[fn] = configFileStat{modTime: fi.ModTime(), size: fi.Size(), hash: sha256.Sum256(data)}
+			}
+		}
+	}
+	conf := new(T)
+	err := w.Loader.Load(conf)
+	if err == nil && w.validate != nil {
+		if err = w.validate(conf); err != nil {
+			err = fmt.Errorf("config validate: %v", err)
+		}
+	}
+
+	w.mu.Lock()
+	w.lastErr = err
+	for fn, st := range stats {
+		w.stats[fn] = st
+	}
+	callbacks := make([]func(old, new *T), len(w.callbacks))
+	copy(callbacks, w.callbacks)
+	w.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	var old *T
+	if v := w.value.Load(); v != nil {
+		old = v.(*T)
+	}
+	w.value.Store(conf)
+	if old != nil {
+		for _, cb := range callbacks {
+			callConfigCallback(cb, old, conf)
+		}
+	}
+	return true, nil
+}
+
+// callConfigCallback 回调函数 panic 时输出错误，不影响其它回调函数和监视 goroutine
+func callConfigCallback[T any](cb func(old, new *T), old, new *T) {
+	defer func() {
+		if r := recover(); r != nil {
+			LogTrace(TextRed, 2, "config change callback panic:", r)
+		}
+	}()
+	cb(old, new)
+}
@@ -0,0 +1,51 @@
+package bcg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigWatcherDeletedFile(t *testing.T) {
+	type conf struct {
+		Port int `json:"port" default:"80"`
+	}
+	fn := filepath.Join(t.TempDir(), "conf.json")
+	if err := os.WriteFile(fn, []byte(`{"port": 8080}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewConfigWatcher[conf](nil, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.changed() {
+		t.Fatalf("unchanged file reported as changed")
+	}
+
+	// 删除只在下一次检查时报告一次
+	if err = os.Remove(fn); err != nil {
+		t.Fatal(err)
+	}
+	if !w.changed() {
+		t.Errorf("deleted file not reported")
+	}
+	for i := 0; i < 3; i++ {
+		if w.changed() {
+			t.Errorf("deleted file reported again in poll %d", i)
+		}
+	}
+
+	// 重新创建后报告一次变化，并且可以加载新配置
+	if err = os.WriteFile(fn, []byte(`{"port": 9090}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !w.Check() {
+		t.Fatalf("recreated file not reloaded: %v", w.LastError())
+	}
+	if w.Get().Port != 9090 {
+		t.Errorf("port = %d, want 9090", w.Get().Port)
+	}
+	if w.changed() {
+		t.Errorf("recreated file reported again")
+	}
+}
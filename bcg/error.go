@@ -0,0 +1,181 @@
+package bcg
+
+// error 定义了带错误码的错误类型 Error，它记录了错误码、错误信息、被包装的原始错误、创建位置的调用堆栈和附加字段。
+// Error 实现了 Unwrap，可以使用 errors.Is 和 errors.As 检查错误链，CheckError 会输出错误码、完整的错误链和创建位置。
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Error 带错误码的错误，Cause 是被包装的原始错误，Fields 是输出到日志的附加信息，比如玩家 id。
+// 应该使用 NewError、Errorf 或者 WrapError 创建，直接构造的 Error 没有创建位置
+type Error struct {
+	Code   int
+	Msg    string
+	Cause  error
+	Fields map[string]interface{}
+	pcs    []uintptr
+}
+
+// NewError 生成一个错误，并记录调用 NewError 的位置
+func NewError(code int, msg string) *Error {
+	return newError(code, msg, nil)
+}
+
+// Errorf 和 NewError 相同，错误信息可以格式化
+func Errorf(code int, format string, v ...interface{}) *Error {
+	return newError(code, fmt.Sprintf(format, v...), nil)
+}
+
+// WrapError 包装一个已有的错误，err 为 nil 时返回 nil，这样可以直接 return WrapError(err, ...)。
+// 返回值的类型是 error 而不是 *Error，避免 nil 的 *Error 被当作非 nil 的 error 返回
+func WrapError(err error, code int, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return newError(code, msg, err)
+}
+
+func newError(code int, msg string, cause error) *Error {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	return &Error{Code: code, Msg: msg, Cause: cause, pcs: pcs[:n]}
+}
+
+func (e *Error) Error() string {
+	if e.Cause == nil {
+		return e.Msg
+	}
+	if e.Msg == "" {
+		return e.Cause.Error()
+	}
+	return e.Msg + ": " + e.Cause.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is 错误码相同的两个 Error 被认为是同一个错误，所以可以用 errors.Is(err, NewError(code, "")) 检查错误码
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && e.Code != 0 && t.Code == e.Code
+}
+
+// WithField 添加一个附加字段，返回 e 本身，可以连续调用
+func (e *Error) WithField(key string, v interface{}) *Error {
+	if e.Fields == nil {
+		e.Fields = map[string]interface{}{}
+	}
+	e.Fields[key] = v
+	return e
+}
+
+// Location 返回错误创建的位置，格式和日志的 trace 相同，比如 /path/file.go:12
+func (e *Error) Location() string {
+	if len(e.pcs) == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames(e.pcs[:1]).Next()
+	return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+}
+
+// Stack 返回错误创建时的调用堆栈，每一项是 "函数名 文件:行号"，没有记录堆栈时返回 nil
+func (e *Error) Stack() []string {
+	if len(e.pcs) == 0 {
+		return nil
+	}
+	stack := make([]string, 0, len(e.pcs))
+	frames := runtime.CallersFrames(e.pcs)
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// Detail 返回用于日志的错误描述，包括错误码、完整的错误链、附加字段和创建位置。
+// 创建位置使用错误链中最内层的 Error，即错误最初产生的位置，外层包装的位置依次附加在后面
+func (e *Error) Detail() string {
+	return e.detail(e.Error(), e)
+}
+
+// detail root 是错误链的起点，用于查找错误链中所有 Error 的位置
+func (e *Error) detail(chain string, root error) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%d] %s", e.Code, chain)
+	if len(e.Fields) > 0 {
+		keys := make([]string, 0, len(e.Fields))
+		for key := range e.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		sb.WriteString(" {")
+		for i, key := range keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "%s: %v", key, e.Fields[key])
+		}
+		sb.WriteString("}")
+	}
+	sb.WriteString(e.locations(root))
+	return sb.String()
+}
+
+// locations 返回错误链中所有 Error 的创建位置，最内层的在前。errors.Join 等多个错误的链不能按顺序遍历，
+// 这时只使用 e 的位置
+func (e *Error) locations(root error) string {
+	var locs []string
+	for err := root; err != nil; err = errors.Unwrap(err) {
+		if x, ok := err.(*Error); ok {
+			if loc := x.Location(); loc != "" {
+				locs = append(locs, loc)
+			}
+		}
+	}
+	if len(locs) == 0 {
+		if loc := e.Location(); loc != "" {
+			locs = append(locs, loc)
+		}
+	}
+	if len(locs) == 0 {
+		return ""
+	}
+	s := " at " + locs[len(locs)-1]
+	for i := len(locs) - 2; i >= 0; i-- {
+		s += ", wrapped at " + locs[i]
+	}
+	return s
+}
+
+// ErrorCode 返回错误链中第一个 Error 的错误码，没有则返回 0
+func ErrorCode(err error) int {
+	if e := asError(err); e != nil {
+		return e.Code
+	}
+	return 0
+}
+
+func asError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
+
+// errorDetail 如果错误链中有 Error，返回包括错误码和所有创建位置的描述（见 Detail），否则返回 err.Error()
+func errorDetail(err error) string {
+	if e := asError(err); e != nil {
+		return e.detail(err.Error(), err)
+	}
+	return err.Error()
+}
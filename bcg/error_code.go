@@ -0,0 +1,146 @@
+package bcg
+
+// error_code 是错误码的注册表，每个错误码只需要声明一次，并为每种语言提供一个消息模板。
+// 模板中的 {name} 会被 Error 的附加字段替换，比如 "道具 {item} 不存在"。
+// 注册表可以通过 LoadErrorCodes 从 JSON 文件加载，文件格式为：
+// {"1001": {"zh": "道具 {item} 不存在", "en": "item {item} not found"}}
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrCodeUnknown 是 ErrorResponse 对不带错误码的错误使用的错误码
+const ErrCodeUnknown = -1
+
+// DefaultLocale 查找消息时，如果指定的语言没有对应的模板，使用这个语言的模板
+var DefaultLocale = "zh"
+
+var errorCodes = struct {
+	sync.RWMutex
+	msgs map[int]map[string]string
+}{msgs: map[int]map[string]string{}}
+
+// RegisterErrorCode 注册一个错误码，msgs 的 key 是语言，value 是消息模板。重复注册会合并模板，相同语言的模板会被覆盖
+func RegisterErrorCode(code int, msgs map[string]string) {
+	errorCodes.Lock()
+	defer errorCodes.Unlock()
+	m := errorCodes.msgs[code]
+	if m == nil {
+		m = map[string]string{}
+		errorCodes.msgs[code] = m
+	}
+	for locale, msg := range msgs {
+		m[locale] = msg
+	}
+}
+
+// LoadErrorCodes 从 JSON 文件加载错误码，文件格式见本文件开头的说明，错误码不是整数时返回 false
+func LoadErrorCodes(fn string) bool {
+	conf := map[string]map[string]string{}
+	if !JsonLoadConf(fn, &conf) {
+		return false
+	}
+	codes := make(map[int]map[string]string, len(conf))
+	for key, msgs := range conf {
+		code, err := strconv.Atoi(key)
+		if err != nil {
+			LogTrace(TextRed, 1, "error code is not an integer:", key)
+			return false
+		}
+		codes[code] = msgs
+	}
+	for code, msgs := range codes {
+		RegisterErrorCode(code, msgs)
+	}
+	return true
+}
+
+// ErrorCodeRegistered 检查错误码是否已经注册
+func ErrorCodeRegistered(code int) bool {
+	errorCodes.RLock()
+	defer errorCodes.RUnlock()
+	_, ok := errorCodes.msgs[code]
+	return ok
+}
+
+// ErrorTemplate 返回错误码在指定语言的模板，查找顺序是 locale，locale 的语言部分（比如 zh-CN 的 zh），DefaultLocale
+func ErrorTemplate(code int, locale string) (string, bool) {
+	errorCodes.RLock()
+	defer errorCodes.RUnlock()
+	msgs, ok := errorCodes.msgs[code]
+	if !ok {
+		return "", false
+	}
+	if msg, ok := msgs[locale]; ok {
+		return msg, true
+	}
+	if pos := strings.IndexAny(locale, "-_"); pos != -1 {
+		if msg, ok := msgs[locale[:pos]]; ok {
+			return msg, true
+		}
+	}
+	msg, ok := msgs[DefaultLocale]
+	return msg, ok
+}
+
+// ErrorMessage 返回错误码在指定语言的消息，模板中的 {name} 被 fields 中对应的值替换，
+// 错误码没有注册时返回 "error <code>"
+func ErrorMessage(code int, locale string, fields map[string]interface{}) string {
+	tpl, ok := ErrorTemplate(code, locale)
+	if !ok {
+		return fmt.Sprintf("error %d", code)
+	}
+	return renderErrorTemplate(tpl, fields)
+}
+
+func renderErrorTemplate(tpl string, fields map[string]interface{}) string {
+	if len(fields) == 0 || strings.IndexByte(tpl, '{') == -1 {
+		return tpl
+	}
+	pairs := make([]string, 0, len(fields)*2)
+	for key, v := range fields {
+		pairs = append(pairs, "{"+key+"}", fmt.Sprint(v))
+	}
+	return strings.NewReplacer(pairs...).Replace(tpl)
+}
+
+// CodeError 使用注册的错误码生成 Error，kv 是成对的附加字段，比如 CodeError(1001, "item", id)，
+// Error 的 Msg 使用 DefaultLocale 的消息
+func CodeError(code int, kv ...interface{}) *Error {
+	e := newError(code, "", nil)
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.WithField(fmt.Sprint(kv[i]), kv[i+1])
+	}
+	e.Msg = ErrorMessage(code, DefaultLocale, e.Fields)
+	return e
+}
+
+// LocalizedMessage 返回错误在指定语言的消息。错误码没有注册时返回 Error 的 Msg，错误链中没有 Error 时返回 err.Error()
+func LocalizedMessage(err error, locale string) string {
+	e := asError(err)
+	if e == nil {
+		return err.Error()
+	}
+	if !ErrorCodeRegistered(e.Code) && e.Msg != "" {
+		return e.Msg
+	}
+	return ErrorMessage(e.Code, locale, e.Fields)
+}
+
+// ErrorResponse 生成返回给客户端的错误对象 {"code": code, "msg": msg}，消息使用指定的语言。
+// 没有错误码的错误使用 ErrCodeUnknown，消息不包含错误的内部细节
+func ErrorResponse(err error, locale string) JsonObject {
+	js := NewJsonObject()
+	code := ErrorCode(err)
+	if code == 0 {
+		js.SetValue("code", ErrCodeUnknown)
+		js.SetValue("msg", ErrorMessage(ErrCodeUnknown, locale, nil))
+		return js
+	}
+	js.SetValue("code", code)
+	js.SetValue("msg", LocalizedMessage(err, locale))
+	return js
+}
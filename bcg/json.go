@@ -1,7 +1,6 @@
 package bcg
 
 import (
-	"bytes"
 	"encoding/json"
 	"strconv"
 )
@@ -76,6 +75,9 @@ func (js *JsonObject) GetFloat64(key string) (float64, bool) {
 	switch val.(type) {
 	case float64:
 		return val.(float64), true
+	case json.Number:
+		f, err := val.(json.Number).Float64()
+		return f, err == nil
 	default:
 		return 0, false
 	}
@@ -108,7 +110,15 @@ func (js *JsonObject) GetBool(key string) (bool, bool) {
 		return false, false
 	}
 }
+// SetValueTo 把 key 对应的值设置到 v 指向的变量，下面列出的指针类型保持原来的转换规则，
+// 其它类型（结构体、切片、time.Time 等）通过 json 数据模型转换，需要错误信息时使用 Get。
+// 如果对象是用 ParseStringUseNumber 等函数解析的，整数会被精确转换，有小数部分或者超出范围时返回 false
 func (js *JsonObject) SetValueTo(key string, v interface{}) (r bool) {
+	if n, ok := (*js)[key].(json.Number); ok {
+		if r, handled := setNumberTo(n, v); handled {
+			return r
+		}
+	}
 	var val float64
 	switch v.(type) {
 	case *bool:
@@ -171,6 +181,10 @@ func (js *JsonObject) SetValueTo(key string, v interface{}) (r bool) {
 		*v.(*JsonObject), r = js.GetJson(key)
 	case *[]interface{}:
 		*v.(*[]interface{}), r = js.GetArray(key)
+	default:
+		if jv, ok := (*js)[key]; ok {
+			r = convertJson(jv, v) == nil
+		}
 	}
 	return r
 }
@@ -253,37 +267,34 @@ func JsonParseBytes(src []byte, pobj interface{}) bool {
 	err := json.Unmarshal(src, pobj)
 	return err == nil
 }
+// JsonToString 序列化失败时输出错误并返回空字串，需要错误时使用 JsonEncodeString
 func JsonToString(obj interface{}, format bool) string {
-	data, _ := json.Marshal(obj)
-	if format {
-		var str bytes.Buffer
-		err := json.Indent(&str, data, "", "\t")
-		if err != nil {
-			return ""
-		}
-		return str.String()
-	} else {
-		return string(data)
+	str, err := JsonEncodeString(obj, jsonFormatOptions(format))
+	if err != nil {
+		LogRed(err.Error())
+		return ""
 	}
+	return str
 }
+
+// JsonToBytes 序列化失败时输出错误并返回 nil，需要错误时使用 JsonEncode
 func JsonToBytes(obj interface{}, format bool) []byte {
-	data, _ := json.Marshal(obj)
-	if format {
-		var str bytes.Buffer
-		err := json.Indent(&str, data, "", "\t")
-		if err != nil {
-			return nil
-		}
-		return str.Bytes()
-	} else {
-		return data
+	data, err := JsonEncode(obj, jsonFormatOptions(format))
+	if err != nil {
+		LogRed(err.Error())
+		return nil
 	}
+	return data
 }
 func JsonLoadConf(fn string, conf interface{}) bool {
 	data := ReadFile(fn)
 	return JsonParseBytes(data, conf)
 }
+// JsonSaveConf 序列化失败时不会写文件，需要错误时使用 JsonSaveConfWith
 func JsonSaveConf(fn string, conf interface{}) bool {
 	data := JsonToBytes(conf, true)
+	if data == nil {
+		return false
+	}
 	return nil == SaveFile(fn, data)
 }
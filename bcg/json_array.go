@@ -0,0 +1,173 @@
+package bcg
+
+// json_array 提供 JsonArray 按下标读取元素、插入删除元素和按类型遍历的函数。
+// 下标和 GetPath 的路径一样可以是负数，-1 表示最后一个元素，下标越界时 Get 函数返回 false。
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+func (ja *JsonArray) Len() int {
+	return len(*ja)
+}
+func (ja *JsonArray) GetInterface(i int) interface{} {
+	i, ok := sliceIndex(i, len(*ja))
+	if !ok {
+		return nil
+	}
+	return (*ja)[i]
+}
+func (ja *JsonArray) GetString(i int) (string, bool) {
+	s, ok := ja.GetInterface(i).(string)
+	return s, ok
+}
+func (ja *JsonArray) GetFloat64(i int) (float64, bool) {
+	switch val := ja.GetInterface(i).(type) {
+	case float64:
+		return val, true
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// GetInt64 精确读取整数，和 JsonObject 的 GetInt64 相同，有小数部分或者超出范围时返回错误
+func (ja *JsonArray) GetInt64(i int) (int64, error) {
+	v, err := numberInt64(ja.GetInterface(i))
+	if err != nil {
+		return 0, fmt.Errorf("json index %d: %v", i, err)
+	}
+	return v, nil
+}
+
+// GetUint64 精确读取无符号整数，和 JsonObject 的 GetUint64 相同
+func (ja *JsonArray) GetUint64(i int) (uint64, error) {
+	v, err := numberUint64(ja.GetInterface(i))
+	if err != nil {
+		return 0, fmt.Errorf("json index %d: %v", i, err)
+	}
+	return v, nil
+}
+
+// GetBigInt 读取任意大小的整数，和 JsonObject 的 GetBigInt 相同
+func (ja *JsonArray) GetBigInt(i int) (*big.Int, error) {
+	v, err := numberBigInt(ja.GetInterface(i))
+	if err != nil {
+		return nil, fmt.Errorf("json index %d: %v", i, err)
+	}
+	return v, nil
+}
+
+// GetDecimalString 返回数字的十进制字串，和 JsonObject 的 GetDecimalString 相同
+func (ja *JsonArray) GetDecimalString(i int) (string, error) {
+	r, err := numberRat(ja.GetInterface(i))
+	if err != nil {
+		return "", fmt.Errorf("json index %d: %v", i, err)
+	}
+	return decimalString(r), nil
+}
+func (ja *JsonArray) GetBool(i int) (bool, bool) {
+	b, ok := ja.GetInterface(i).(bool)
+	return b, ok
+}
+func (ja *JsonArray) GetJson(i int) (JsonObject, bool) {
+	return asJsonMap(ja.GetInterface(i))
+}
+func (ja *JsonArray) GetArray(i int) ([]interface{}, bool) {
+	a, ok := asJsonSlice(ja.GetInterface(i))
+	if !ok {
+		return []interface{}{}, false
+	}
+	return a, true
+}
+
+// SetValue 设置下标 i 的元素，下标越界时返回 false
+func (ja *JsonArray) SetValue(i int, v interface{}) bool {
+	i, ok := sliceIndex(i, len(*ja))
+	if ok {
+		(*ja)[i] = v
+	}
+	return ok
+}
+func (ja *JsonArray) Append(v ...interface{}) {
+	*ja = append(*ja, v...)
+}
+
+// Insert 在下标 i 之前插入元素，i 等于数组长度时追加到最后，下标越界时返回 false
+func (ja *JsonArray) Insert(i int, v ...interface{}) bool {
+	if i != len(*ja) {
+		var ok bool
+		if i, ok = sliceIndex(i, len(*ja)); !ok {
+			return false
+		}
+	}
+	a := make(JsonArray, 0, len(*ja)+len(v))
+	a = append(a, (*ja)[:i]...)
+	a = append(a, v...)
+	*ja = append(a, (*ja)[i:]...)
+	return true
+}
+
+// Remove 删除下标 i 的元素，后面的元素前移，下标越界时返回 false
+func (ja *JsonArray) Remove(i int) bool {
+	i, ok := sliceIndex(i, len(*ja))
+	if !ok {
+		return false
+	}
+	*ja = append((*ja)[:i], (*ja)[i+1:]...)
+	return true
+}
+
+// ForEach 遍历所有元素，回调函数返回 false 时停止遍历
+func (ja *JsonArray) ForEach(cb func(i int, v interface{}) bool) {
+	for i, v := range *ja {
+		if !cb(i, v) {
+			return
+		}
+	}
+}
+
+// ForEachJson 只遍历类型是对象的元素，i 是元素在数组中的下标
+func (ja *JsonArray) ForEachJson(cb func(i int, js JsonObject) bool) {
+	for i, v := range *ja {
+		if js, ok := asJsonMap(v); ok && !cb(i, js) {
+			return
+		}
+	}
+}
+
+// ForEachString 只遍历类型是字串的元素
+func (ja *JsonArray) ForEachString(cb func(i int, s string) bool) {
+	for i, v := range *ja {
+		if s, ok := v.(string); ok && !cb(i, s) {
+			return
+		}
+	}
+}
+
+// ForEachFloat64 只遍历类型是数字的元素
+func (ja *JsonArray) ForEachFloat64(cb func(i int, f float64) bool) {
+	for i := range *ja {
+		if f, ok := ja.GetFloat64(i); ok && !cb(i, f) {
+			return
+		}
+	}
+}
+
+// ToJsonObjects 把对象数组转换为 []JsonObject，不是对象的元素会被跳过，此时第二个返回值为 false
+func (ja *JsonArray) ToJsonObjects() ([]JsonObject, bool) {
+	list := make([]JsonObject, 0, len(*ja))
+	all := true
+	for _, v := range *ja {
+		if js, ok := asJsonMap(v); ok {
+			list = append(list, js)
+		} else {
+			all = false
+		}
+	}
+	return list, all
+}
@@ -0,0 +1,57 @@
+package bcg
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestJsonArrayNumbers(t *testing.T) {
+	var ja JsonArray
+	if err := ja.ParseStringUseNumber(`[18446744073709551615, 123456789012345678901234567890, 1.5e3, 0.25, -1, "x"]`); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := ja.GetUint64(0); err != nil || v != 18446744073709551615 {
+		t.Errorf("GetUint64(0) = %v, %v", v, err)
+	}
+	if _, err := ja.GetInt64(0); err == nil {
+		t.Errorf("GetInt64(0): expected overflow error")
+	}
+	if _, err := ja.GetUint64(4); err == nil {
+		t.Errorf("GetUint64(4): expected error for negative number")
+	}
+	if v, err := ja.GetUint64(2); err != nil || v != 1500 {
+		t.Errorf("GetUint64(2) = %v, %v", v, err)
+	}
+
+	want, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	if v, err := ja.GetBigInt(1); err != nil || v.Cmp(want) != 0 {
+		t.Errorf("GetBigInt(1) = %v, %v", v, err)
+	}
+	if v, err := ja.GetBigInt(-2); err != nil || v.Int64() != -1 {
+		t.Errorf("GetBigInt(-2) = %v, %v", v, err)
+	}
+	if _, err := ja.GetBigInt(3); err == nil {
+		t.Errorf("GetBigInt(3): expected error for fraction")
+	}
+
+	decimals := map[int]string{1: "123456789012345678901234567890", 2: "1500", 3: "0.25", 4: "-1"}
+	for i, want := range decimals {
+		if v, err := ja.GetDecimalString(i); err != nil || v != want {
+			t.Errorf("GetDecimalString(%d) = %q, %v, want %q", i, v, err, want)
+		}
+	}
+
+	// 不是数字或者下标越界时返回错误
+	for _, i := range []int{5, 6, -7} {
+		if _, err := ja.GetUint64(i); err == nil {
+			t.Errorf("GetUint64(%d): expected error", i)
+		}
+		if _, err := ja.GetBigInt(i); err == nil {
+			t.Errorf("GetBigInt(%d): expected error", i)
+		}
+		if _, err := ja.GetDecimalString(i); err == nil {
+			t.Errorf("GetDecimalString(%d): expected error", i)
+		}
+	}
+}
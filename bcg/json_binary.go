@@ -0,0 +1,244 @@
+package bcg
+
+// json_binary MessagePack 和 CBOR 编码的公共部分，用于向客户端推送数据量较大的玩家状态。
+// 两种编码使用和 json 相同的数据模型：对象、数组、字串、数字、bool、nil，另外支持 []byte（json 中会被编码为 base64 字串）。
+// 编码时没有小数部分的数字使用整数编码，可以被 float32 精确表示的数字使用 float32 编码，对象的 key 按顺序输出，
+// 所以相同的数据编码结果相同。结构体等其它类型先按 json 序列化规则转换为数据模型中的值再编码。
+// 超出 int64 和 uint64 范围的整数 CBOR 使用 bignum（tag 2 和 3）编码，MessagePack 没有对应的类型，编码时返回错误，
+// 不会转换为 float64 丢失精度。
+// 解码时整数和 ParseString 一样转换为 float64，超出 float64 精确范围（2^53）的整数转换为 json.Number，不会丢失精度。
+// 解码的数据来自客户端，所以会检查长度和嵌套深度，恶意数据不会导致分配大量内存或者栈溢出。
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// binMaxDepth 编码和解码时允许的最大嵌套深度
+const binMaxDepth = 512
+
+// maxSafeInteger float64 可以精确表示的最大整数
+const maxSafeInteger = 1 << 53
+
+var errBinTruncated = errors.New("unexpected end of data")
+
+type binReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *binReader) readByte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, errBinTruncated
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+// readBytes 返回的切片引用原来的数据，保存时需要复制
+func (r *binReader) readBytes(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errBinTruncated
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// readUint 读取 size 字节的大端整数，size 为 1、2、4、8
+func (r *binReader) readUint(size int) (uint64, error) {
+	b, err := r.readBytes(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+// checkCount 每个元素至少占用 size 个字节，元素数量超过剩余的字节数能容纳的数量时数据一定是错误的，在分配内存之前检查
+func (r *binReader) checkCount(n, size uint64) error {
+	if n > uint64(len(r.data)-r.pos)/size {
+		return errBinTruncated
+	}
+	return nil
+}
+
+func binInt(i int64) interface{} {
+	if i >= -maxSafeInteger && i <= maxSafeInteger {
+		return float64(i)
+	}
+	return json.Number(strconv.FormatInt(i, 10))
+}
+
+func binUint(u uint64) interface{} {
+	if u <= maxSafeInteger {
+		return float64(u)
+	}
+	return json.Number(strconv.FormatUint(u, 10))
+}
+
+// binFloatInt 没有小数部分并且在 int64 范围内的数字使用整数编码
+func binFloatInt(f float64) (int64, bool) {
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// binWriter MessagePack 和 CBOR 编码器共同的接口，binEncode 负责遍历数据，编码器只负责输出各种类型的值
+type binWriter interface {
+	writeNil()
+	writeBool(b bool)
+	writeInt(i int64)
+	writeUint(u uint64)
+	writeFloat(f float64)
+	// writeBigInt 输出超出 int64 和 uint64 范围的整数，编码不支持时返回错误
+	writeBigInt(b *big.Int) error
+	writeString(s string)
+	writeBytes(b []byte)
+	writeArrayHeader(n int)
+	writeMapHeader(n int)
+}
+
+func binEncode(w binWriter, v interface{}, depth int) error {
+	if depth > binMaxDepth {
+		return errors.New("nesting too deep")
+	}
+	switch x := v.(type) {
+	case nil:
+		w.writeNil()
+		return nil
+	case bool:
+		w.writeBool(x)
+		return nil
+	case string:
+		w.writeString(x)
+		return nil
+	case []byte:
+		w.writeBytes(x)
+		return nil
+	case float64:
+		if i, ok := binFloatInt(x); ok {
+			w.writeInt(i)
+		} else {
+			w.writeFloat(x)
+		}
+		return nil
+	case float32:
+		binEncodeFloat32(w, x)
+		return nil
+	case json.Number:
+		return binEncodeNumber(w, x)
+	case json.Marshaler:
+		return binEncodeNormalized(w, v, depth)
+	}
+	if m, ok := asJsonMap(v); ok {
+		w.writeMapHeader(len(m))
+		for _, k := range sortedKeys(m) {
+			w.writeString(k)
+			if err := binEncode(w, m[k], depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if a, ok := asJsonSlice(v); ok {
+		w.writeArrayHeader(len(a))
+		for _, item := range a {
+			if err := binEncode(w, item, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.writeInt(rv.Int())
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		w.writeUint(rv.Uint())
+		return nil
+	}
+	return binEncodeNormalized(w, v, depth)
+}
+
+func binEncodeFloat32(w binWriter, f float32) {
+	if i, ok := binFloatInt(float64(f)); ok {
+		w.writeInt(i)
+	} else {
+		w.writeFloat(float64(f))
+	}
+}
+
+func binEncodeNumber(w binWriter, n json.Number) error {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		w.writeInt(i)
+		return nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		w.writeUint(u)
+		return nil
+	}
+	if b, ok := new(big.Int).SetString(string(n), 10); ok {
+		return w.writeBigInt(b)
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", string(n))
+	}
+	if i, ok := binFloatInt(f); ok {
+		w.writeInt(i)
+	} else {
+		w.writeFloat(f)
+	}
+	return nil
+}
+
+func binEncodeNormalized(w binWriter, v interface{}, depth int) error {
+	nv, err := jsonNormalize(v)
+	if err != nil {
+		return err
+	}
+	return binEncode(w, nv, depth+1)
+}
+
+// setBinObject 把解码的值保存到 JsonObject，和 ParseBytes 一样合并到原来的对象
+func setBinObject(js *JsonObject, v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return errors.New("top-level value is not an object")
+	}
+	if *js == nil {
+		*js = m
+		return nil
+	}
+	for k, item := range m {
+		(*js)[k] = item
+	}
+	return nil
+}
+
+func setBinArray(ja *JsonArray, v interface{}) error {
+	a, ok := v.([]interface{})
+	if !ok {
+		return errors.New("top-level value is not an array")
+	}
+	*ja = a
+	return nil
+}
@@ -0,0 +1,270 @@
+package bcg
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// binCase 每个值放在对象的 "v" 和数组的第一个元素中编码
+var binCases = []struct {
+	name string
+	v    interface{}
+	// msgpackErr MessagePack 不能表示这个值，编码时应该返回错误
+	msgpackErr bool
+}{
+	{"nil", nil, false},
+	{"true", true, false},
+	{"false", false, false},
+	{"zero", int64(0), false},
+	{"fixint", int64(127), false},
+	{"negative fixint", int64(-32), false},
+	{"int8", int64(math.MinInt8), false},
+	{"int16", int64(math.MinInt16), false},
+	{"int32", int64(math.MinInt32), false},
+	{"max int64", int64(math.MaxInt64), false},
+	{"min int64", int64(math.MinInt64), false},
+	{"max safe integer", int64(maxSafeInteger), false},
+	{"uint64 above int64", uint64(math.MaxInt64) + 1, false},
+	{"max uint64", uint64(math.MaxUint64), false},
+	{"float32", 1.5, false},
+	{"float64", 0.1, false},
+	{"number", json.Number("12345"), false},
+	{"number fraction", json.Number("1.25"), false},
+	{"bignum", json.Number("123456789012345678901234567890"), true},
+	{"negative bignum", json.Number("-123456789012345678901234567890"), true},
+	{"empty string", "", false},
+	{"string", "玩家 <tag> \"quoted\"", false},
+	{"long string", strings.Repeat("x", 70000), false},
+	{"bytes", []byte{0, 1, 2, 0xff}, false},
+	{"empty bytes", []byte{}, false},
+	{"nested map", map[string]interface{}{"b": map[string]interface{}{"c": []interface{}{int64(1), "x", nil}}, "a": true}, false},
+	{"nested array", []interface{}{[]interface{}{}, []interface{}{map[string]interface{}{}}, float64(3)}, false},
+	{"json object", JsonObject{"gold": int64(100), "items": JsonArray{"sword", "shield"}}, false},
+}
+
+// binJson 用 encoding/json 序列化后比较，[]byte 和 json.Number 的结果与解码后的值相同
+func binJson(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	return string(b)
+}
+
+type binCodec struct {
+	name      string
+	objEncode func(js JsonObject) ([]byte, error)
+	objDecode func(js *JsonObject, data []byte) error
+	arrEncode func(ja JsonArray) ([]byte, error)
+	arrDecode func(ja *JsonArray, data []byte) error
+}
+
+var binCodecs = []binCodec{
+	{
+		"msgpack",
+		func(js JsonObject) ([]byte, error) { return js.ToMsgpack() },
+		func(js *JsonObject, data []byte) error { return js.ParseMsgpack(data) },
+		func(ja JsonArray) ([]byte, error) { return ja.ToMsgpack() },
+		func(ja *JsonArray, data []byte) error { return ja.ParseMsgpack(data) },
+	},
+	{
+		"cbor",
+		func(js JsonObject) ([]byte, error) { return js.ToCbor() },
+		func(js *JsonObject, data []byte) error { return js.ParseCbor(data) },
+		func(ja JsonArray) ([]byte, error) { return ja.ToCbor() },
+		func(ja *JsonArray, data []byte) error { return ja.ParseCbor(data) },
+	},
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for _, codec := range binCodecs {
+		for _, c := range binCases {
+			t.Run(codec.name+"/"+c.name, func(t *testing.T) {
+				wantErr := c.msgpackErr && codec.name == "msgpack"
+				js := JsonObject{"v": c.v}
+				data, err := codec.objEncode(js)
+				if wantErr {
+					if err == nil {
+						t.Fatalf("object: expected error, got %d bytes", len(data))
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("object encode: %v", err)
+				}
+				got := JsonObject{}
+				if err = codec.objDecode(&got, data); err != nil {
+					t.Fatalf("object decode: %v", err)
+				}
+				if want, have := binJson(t, js), binJson(t, got); want != have {
+					t.Errorf("object:\nwant %.200s\nhave %.200s", want, have)
+				}
+
+				ja := JsonArray{c.v, "tail"}
+				data, err = codec.arrEncode(ja)
+				if err != nil {
+					t.Fatalf("array encode: %v", err)
+				}
+				var gotArr JsonArray
+				if err = codec.arrDecode(&gotArr, data); err != nil {
+					t.Fatalf("array decode: %v", err)
+				}
+				if want, have := binJson(t, ja), binJson(t, gotArr); want != have {
+					t.Errorf("array:\nwant %.200s\nhave %.200s", want, have)
+				}
+			})
+		}
+	}
+}
+
+func TestBinaryDecodeNumbers(t *testing.T) {
+	// 不超过 2^53 的整数解码为 float64，和 ParseString 相同；更大的整数解码为 json.Number
+	for _, codec := range binCodecs {
+		js := JsonObject{"small": int64(maxSafeInteger), "big": int64(maxSafeInteger + 1), "u": uint64(math.MaxUint64)}
+		data, err := codec.objEncode(js)
+		if err != nil {
+			t.Fatalf("%s: %v", codec.name, err)
+		}
+		got := JsonObject{}
+		if err = codec.objDecode(&got, data); err != nil {
+			t.Fatalf("%s: %v", codec.name, err)
+		}
+		if v, ok := got["small"].(float64); !ok || v != maxSafeInteger {
+			t.Errorf("%s: small = %#v", codec.name, got["small"])
+		}
+		if v, ok := got["big"].(json.Number); !ok || v != "9007199254740993" {
+			t.Errorf("%s: big = %#v", codec.name, got["big"])
+		}
+		if v, ok := got["u"].(json.Number); !ok || v != "18446744073709551615" {
+			t.Errorf("%s: u = %#v", codec.name, got["u"])
+		}
+	}
+}
+
+func TestCborIndefiniteLength(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		// {_ "a": [_ 1, 2], "b": (_ "x", "yz")}
+		{"map array text", []byte{0xbf, 0x61, 'a', 0x9f, 0x01, 0x02, 0xff, 0x61, 'b', 0x7f, 0x61, 'x', 0x62, 'y', 'z', 0xff, 0xff}, `{"a":[1,2],"b":"xyz"}`},
+		// {_ "v": (_ h'01', h'0203')}
+		{"bytes", []byte{0xbf, 0x61, 'v', 0x5f, 0x41, 0x01, 0x42, 0x02, 0x03, 0xff, 0xff}, `{"v":"AQID"}`},
+		// {_ "e": [_ ], "m": {_ }}
+		{"empty", []byte{0xbf, 0x61, 'e', 0x9f, 0xff, 0x61, 'm', 0xbf, 0xff, 0xff}, `{"e":[],"m":{}}`},
+		// {"n": 2(h'010000000000000000')}，2^64
+		{"bignum", []byte{0xa1, 0x61, 'n', 0xc2, 0x49, 0x01, 0, 0, 0, 0, 0, 0, 0, 0}, `{"n":18446744073709551616}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			js := JsonObject{}
+			if err := js.ParseCbor(c.data); err != nil {
+				t.Fatalf("ParseCbor: %v", err)
+			}
+			if have := binJson(t, js); have != c.want {
+				t.Errorf("want %s, have %s", c.want, have)
+			}
+		})
+	}
+}
+
+func TestBinaryDecodeErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		decode func([]byte) (interface{}, error)
+		data   []byte
+	}{
+		{"msgpack truncated", MsgpackUnmarshal, []byte{0xa5, 'a'}},
+		{"msgpack extra bytes", MsgpackUnmarshal, []byte{0xc0, 0xc0}},
+		{"msgpack huge array", MsgpackUnmarshal, []byte{0xdd, 0xff, 0xff, 0xff, 0xff}},
+		{"cbor truncated", CborUnmarshal, []byte{0x65, 'a'}},
+		{"cbor missing break", CborUnmarshal, []byte{0x9f, 0x01}},
+		{"cbor stray break", CborUnmarshal, []byte{0xff}},
+		{"cbor huge map", CborUnmarshal, []byte{0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, c := range cases {
+		if _, err := c.decode(c.data); err == nil {
+			t.Errorf("%s: expected error", c.name)
+		}
+	}
+	deep := append([]byte(strings.Repeat("\x81", binMaxDepth+10)), 0xc0)
+	if _, err := CborUnmarshal(deep); err == nil {
+		t.Errorf("cbor deep nesting: expected error")
+	}
+}
+
+// binBenchData 模拟推送给客户端的玩家状态
+func binBenchData() JsonObject {
+	items := JsonArray{}
+	for i := 0; i < 200; i++ {
+		items = append(items, JsonObject{
+			"id":     int64(100000 + i),
+			"count":  int64(i % 17),
+			"name":   "item_" + strings.Repeat("x", i%10),
+			"bound":  i%3 == 0,
+			"weight": float64(i) * 0.25,
+			"attrs":  JsonArray{int64(i), int64(i * 2), int64(i * 3)},
+		})
+	}
+	return JsonObject{"uid": int64(123456789), "nick": "玩家一号", "gold": int64(987654321), "items": items}
+}
+
+func BenchmarkJsonMarshal(b *testing.B) {
+	js := binBenchData()
+	var data []byte
+	for i := 0; i < b.N; i++ {
+		data, _ = json.Marshal(js)
+	}
+	b.ReportMetric(float64(len(data)), "size")
+}
+
+func BenchmarkMsgpackMarshal(b *testing.B) {
+	js := binBenchData()
+	var data []byte
+	for i := 0; i < b.N; i++ {
+		data, _ = js.ToMsgpack()
+	}
+	b.ReportMetric(float64(len(data)), "size")
+}
+
+func BenchmarkCborMarshal(b *testing.B) {
+	js := binBenchData()
+	var data []byte
+	for i := 0; i < b.N; i++ {
+		data, _ = js.ToCbor()
+	}
+	b.ReportMetric(float64(len(data)), "size")
+}
+
+func BenchmarkJsonUnmarshal(b *testing.B) {
+	data, _ := json.Marshal(binBenchData())
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		js := JsonObject{}
+		_ = json.Unmarshal(data, &js)
+	}
+}
+
+func BenchmarkMsgpackUnmarshal(b *testing.B) {
+	js := binBenchData()
+	data, _ := js.ToMsgpack()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		got := JsonObject{}
+		_ = got.ParseMsgpack(data)
+	}
+}
+
+func BenchmarkCborUnmarshal(b *testing.B) {
+	js := binBenchData()
+	data, _ := js.ToCbor()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		got := JsonObject{}
+		_ = got.ParseCbor(data)
+	}
+}
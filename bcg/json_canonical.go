@@ -0,0 +1,195 @@
+package bcg
+
+// json_canonical 按照 RFC 8785 (JCS) 生成规范化的 json，用于对游戏状态和上链数据签名或者计算哈希。
+// 规范化的 json 没有空白，对象的 key 按 UTF-16 编码排序，数字使用 ECMAScript 的格式，字串只转义必须转义的字符，
+// 所以相同的数据在不同的服务中总是得到相同的字节。
+// 注意 JCS 规定数字是 IEEE 754 双精度浮点数，超过 2^53 的整数会损失精度，这样的数字应该使用字串保存，比如 SetStrInt64。
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// JsonCanonical 返回 v 的规范化 json，v 可以是 JsonObject、JsonArray 或者任何可以被 json.Marshal 的值，结构体的 json tag 有效
+func JsonCanonical(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var model interface{}
+	if err = unmarshalUseNumber(data, &model); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = writeCanonical(&buf, model); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (js *JsonObject) ToCanonical() ([]byte, error) {
+	return JsonCanonical(js)
+}
+func (ja *JsonArray) ToCanonical() ([]byte, error) {
+	return JsonCanonical(ja)
+}
+
+// JsonCanonicalHash 使用 h 计算 v 的规范化 json 的哈希值，比如 JsonCanonicalHash(v, sha3.NewLegacyKeccak256())
+func JsonCanonicalHash(v interface{}, h hash.Hash) ([]byte, error) {
+	data, err := JsonCanonical(v)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// JsonCanonicalSha256 返回 v 的规范化 json 的 SHA-256 哈希值
+func JsonCanonicalSha256(v interface{}) ([]byte, error) {
+	return JsonCanonicalHash(v, sha256.New())
+}
+
+func writeCanonical(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+	case string:
+		writeCanonicalString(buf, val)
+	case json.Number:
+		f, err := strconv.ParseFloat(string(val), 64)
+		if err != nil {
+			return fmt.Errorf("json canonical: number %s: %v", val, err)
+		}
+		s, err := canonicalNumber(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUtf16(keys[i], keys[j])
+		})
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, val[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		return fmt.Errorf("json canonical: unexpected type %T", v)
+	}
+	return nil
+}
+
+// lessUtf16 按 UTF-16 编码比较字串，和按 UTF-8 字节比较的区别在于 U+E000 到 U+FFFF 的字符排在辅助平面字符的后面
+func lessUtf16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// canonicalNumber 按照 ECMAScript Number.prototype.toString 的规则格式化浮点数
+func canonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("json canonical: unsupported number %v", f)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+	// 最短的可以还原的十进制表示，格式为 d.ddde±xx
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	pos := strings.IndexByte(e, 'e')
+	digits := strings.Replace(e[:pos], ".", "", 1)
+	exp, _ := strconv.Atoi(e[pos+1:])
+	k, n := len(digits), exp+1
+
+	var s string
+	switch {
+	case k <= n && n <= 21:
+		s = digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		s = digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		s = "0." + strings.Repeat("0", -n) + digits
+	default:
+		s = digits[:1]
+		if k > 1 {
+			s += "." + digits[1:]
+		}
+		if n-1 >= 0 {
+			s += "e+" + strconv.Itoa(n-1)
+		} else {
+			s += "e" + strconv.Itoa(n-1)
+		}
+	}
+	return sign + s, nil
+}
@@ -0,0 +1,391 @@
+package bcg
+
+// json_cbor CBOR（RFC 8949）编码，数据模型和编码规则见 json_binary.go。
+// 编码时只使用确定长度的格式，超出 uint64 范围的整数使用 bignum 标签（2 和 3）。
+// 解码时支持不确定长度的字串、数组和对象，bignum 解码为 json.Number，其它标签会被忽略，只解码标签的内容，
+// undefined 解码为 nil。
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	cborUint   = 0 << 5
+	cborNegInt = 1 << 5
+	cborBytes  = 2 << 5
+	cborText   = 3 << 5
+	cborArray  = 4 << 5
+	cborMap    = 5 << 5
+	cborTag    = 6 << 5
+	cborSimple = 7 << 5
+
+	// cborIndefinite 不确定长度的附加信息，cborBreakByte 是不确定长度数据的结束符
+	cborIndefinite = 31
+	cborBreakByte  = 0xff
+)
+
+type cborWriter struct {
+	buf []byte
+}
+
+// writeHead 输出主类型和参数，参数使用最短的编码
+func (w *cborWriter) writeHead(major byte, n uint64) {
+	switch {
+	case n < 24:
+		w.buf = append(w.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, major|26), uint32(n))
+	default:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, major|27), n)
+	}
+}
+
+func (w *cborWriter) writeNil() {
+	w.buf = append(w.buf, cborSimple|22)
+}
+
+func (w *cborWriter) writeBool(b bool) {
+	if b {
+		w.buf = append(w.buf, cborSimple|21)
+	} else {
+		w.buf = append(w.buf, cborSimple|20)
+	}
+}
+
+func (w *cborWriter) writeInt(i int64) {
+	if i >= 0 {
+		w.writeHead(cborUint, uint64(i))
+	} else {
+		w.writeHead(cborNegInt, uint64(-1-i))
+	}
+}
+
+func (w *cborWriter) writeUint(u uint64) {
+	w.writeHead(cborUint, u)
+}
+
+func (w *cborWriter) writeFloat(f float64) {
+	if f32 := float32(f); float64(f32) == f {
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, cborSimple|26), math.Float32bits(f32))
+		return
+	}
+	w.buf = binary.BigEndian.AppendUint64(append(w.buf, cborSimple|27), math.Float64bits(f))
+}
+
+func (w *cborWriter) writeBigInt(b *big.Int) error {
+	if b.Sign() >= 0 {
+		w.writeHead(cborTag, 2)
+		w.writeBytes(b.Bytes())
+		return nil
+	}
+	// 负数的 bignum 保存的是 -1-n
+	n := new(big.Int).Neg(b)
+	n.Sub(n, big.NewInt(1))
+	w.writeHead(cborTag, 3)
+	w.writeBytes(n.Bytes())
+	return nil
+}
+
+func (w *cborWriter) writeString(s string) {
+	w.writeHead(cborText, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *cborWriter) writeBytes(b []byte) {
+	w.writeHead(cborBytes, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *cborWriter) writeArrayHeader(n int) {
+	w.writeHead(cborArray, uint64(n))
+}
+
+func (w *cborWriter) writeMapHeader(n int) {
+	w.writeHead(cborMap, uint64(n))
+}
+
+// CborMarshal 把 json 数据模型中的值编码为 CBOR
+func CborMarshal(v interface{}) ([]byte, error) {
+	w := &cborWriter{buf: make([]byte, 0, 256)}
+	if err := binEncode(w, v, 0); err != nil {
+		return nil, fmt.Errorf("cbor: %v", err)
+	}
+	return w.buf, nil
+}
+
+// CborUnmarshal 解码 CBOR 数据，对象解码为 map[string]interface{}，数组解码为 []interface{}，
+// 数据末尾有多余的字节时返回错误
+func CborUnmarshal(data []byte) (interface{}, error) {
+	r := &binReader{data: data}
+	v, err := r.cbor(0)
+	if err == nil && r.pos < len(data) {
+		err = fmt.Errorf("%d extra bytes after value", len(data)-r.pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cbor: offset %d: %v", r.pos, err)
+	}
+	return v, nil
+}
+
+func (js *JsonObject) ToCbor() ([]byte, error) {
+	return CborMarshal(*js)
+}
+
+// ParseCbor 解码 CBOR 对象，和 ParseBytes 一样合并到原来的对象
+func (js *JsonObject) ParseCbor(data []byte) error {
+	v, err := CborUnmarshal(data)
+	if err != nil {
+		return err
+	}
+	if err = setBinObject(js, v); err != nil {
+		return fmt.Errorf("cbor: %v", err)
+	}
+	return nil
+}
+
+func (ja *JsonArray) ToCbor() ([]byte, error) {
+	return CborMarshal(*ja)
+}
+
+func (ja *JsonArray) ParseCbor(data []byte) error {
+	v, err := CborUnmarshal(data)
+	if err != nil {
+		return err
+	}
+	if err = setBinArray(ja, v); err != nil {
+		return fmt.Errorf("cbor: %v", err)
+	}
+	return nil
+}
+
+// cborHead 读取数据项的主类型和参数，indefinite 表示不确定长度
+func (r *binReader) cborHead() (major, info byte, n uint64, indefinite bool, err error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	major, info = b&0xe0, b&0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), false, nil
+	case info <= 27:
+		n, err = r.readUint(1 << (info - 24))
+		return major, info, n, false, err
+	case info == cborIndefinite:
+		return major, info, 0, true, nil
+	}
+	return 0, 0, 0, false, fmt.Errorf("invalid additional info %d", info)
+}
+
+// cborBreak 下一个字节是不确定长度数据的结束符时跳过它并返回 true
+func (r *binReader) cborBreak() bool {
+	if r.pos < len(r.data) && r.data[r.pos] == cborBreakByte {
+		r.pos++
+		return true
+	}
+	return false
+}
+
+func (r *binReader) cbor(depth int) (interface{}, error) {
+	if depth > binMaxDepth {
+		return nil, fmt.Errorf("nesting too deep")
+	}
+	major, info, n, indefinite, err := r.cborHead()
+	if err != nil {
+		return nil, err
+	}
+	if indefinite {
+		switch major {
+		case cborSimple:
+			return nil, errors.New("unexpected break")
+		case cborUint, cborNegInt, cborTag:
+			return nil, fmt.Errorf("invalid indefinite length for major type %d", major>>5)
+		}
+	}
+	switch major {
+	case cborUint:
+		return binUint(n), nil
+	case cborNegInt:
+		if n <= math.MaxInt64 {
+			return binInt(-1 - int64(n)), nil
+		}
+		b := new(big.Int).SetUint64(n)
+		return bigIntNumber(b.Neg(b.Add(b, big.NewInt(1)))), nil
+	case cborBytes, cborText:
+		data, err := r.cborString(major, n, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(data), nil
+		}
+		return data, nil
+	case cborArray:
+		return r.cborArray(n, indefinite, depth)
+	case cborMap:
+		return r.cborMap(n, indefinite, depth)
+	case cborTag:
+		return r.cborTagged(n, depth)
+	}
+	return r.cborSimple(info, n)
+}
+
+// cborString 读取字串或者二进制数据，不确定长度时把各个分段连接起来，返回的数据是复制的
+func (r *binReader) cborString(major byte, n uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		data, err := r.readBytes(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, data...), nil
+	}
+	var buf []byte
+	for {
+		if r.cborBreak() {
+			return buf, nil
+		}
+		m, _, cn, ci, err := r.cborHead()
+		if err != nil {
+			return nil, err
+		}
+		if m != major || ci {
+			return nil, errors.New("invalid chunk in indefinite length string")
+		}
+		data, err := r.readBytes(cn)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, data...)
+	}
+}
+
+func (r *binReader) cborArray(n uint64, indefinite bool, depth int) (interface{}, error) {
+	if indefinite {
+		a := make([]interface{}, 0)
+		for !r.cborBreak() {
+			v, err := r.cbor(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	}
+	if err := r.checkCount(n, 1); err != nil {
+		return nil, err
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := r.cbor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (r *binReader) cborMap(n uint64, indefinite bool, depth int) (interface{}, error) {
+	if !indefinite {
+		if err := r.checkCount(n, 2); err != nil {
+			return nil, err
+		}
+	}
+	m := make(map[string]interface{})
+	for i := uint64(0); indefinite || i < n; i++ {
+		if indefinite && r.cborBreak() {
+			return m, nil
+		}
+		k, err := r.cbor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key must be a string, got %s", JsonTypeName(k))
+		}
+		v, err := r.cbor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+func (r *binReader) cborTagged(tag uint64, depth int) (interface{}, error) {
+	v, err := r.cbor(depth + 1)
+	if err != nil {
+		return nil, err
+	}
+	if tag != 2 && tag != 3 {
+		return v, nil
+	}
+	data, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("bignum tag %d content must be a byte string", tag)
+	}
+	b := new(big.Int).SetBytes(data)
+	if tag == 3 {
+		b.Neg(b.Add(b, big.NewInt(1)))
+	}
+	return bigIntNumber(b), nil
+}
+
+// bigIntNumber 和 binInt 一样，在 float64 精确范围内的整数转换为 float64
+func bigIntNumber(b *big.Int) interface{} {
+	if b.IsInt64() {
+		return binInt(b.Int64())
+	}
+	return json.Number(b.String())
+}
+
+func (r *binReader) cborSimple(info byte, n uint64) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		return halfFloat(uint16(n)), nil
+	case 26:
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 27:
+		return math.Float64frombits(n), nil
+	}
+	return nil, fmt.Errorf("unsupported simple value %d", n)
+}
+
+// halfFloat 把 IEEE 754 半精度浮点数转换为 float64
+func halfFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
@@ -0,0 +1,180 @@
+package bcg
+
+// json_diff 比较两个 JsonObject 或 JsonArray，返回所有不同的地方。变化的路径和 GetPath 的路径格式相同，
+// 可以直接用 GetPath 读取。数组按下标比较，FormatJsonDiff 把结果转换为可以在 console 显示的带颜色的文本。
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	DiffAdded       = "added"
+	DiffRemoved     = "removed"
+	DiffModified    = "modified"
+	DiffTypeChanged = "type_changed"
+)
+
+// JsonChange 一处变化，Added 只有 New，Removed 只有 Old
+type JsonChange struct {
+	Kind string      `json:"kind"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+// MarshalJSON 只省略不存在的一边：Added 没有 old，Removed 没有 new，
+// 其它变化即使值是 0、false、"" 或 null 也会输出，和新增、删除区分开
+func (c JsonChange) MarshalJSON() ([]byte, error) {
+	type change JsonChange
+	switch c.Kind {
+	case DiffAdded:
+		return json.Marshal(struct {
+			Kind string      `json:"kind"`
+			Path string      `json:"path"`
+			New  interface{} `json:"new"`
+		}{c.Kind, c.Path, c.New})
+	case DiffRemoved:
+		return json.Marshal(struct {
+			Kind string      `json:"kind"`
+			Path string      `json:"path"`
+			Old  interface{} `json:"old"`
+		}{c.Kind, c.Path, c.Old})
+	}
+	return json.Marshal(change(c))
+}
+
+// JsonDiff 比较 a 和 b，它们可以是 JsonObject、JsonArray 或者任何 json 数据模型中的值，对象的 key 按字母顺序比较
+func JsonDiff(a, b interface{}) []JsonChange {
+	changes := make([]JsonChange, 0)
+	diffJson("", a, b, &changes)
+	return changes
+}
+
+// JsonTypeName 返回值在 json 中的类型：object、array、string、number、bool 或 null
+func JsonTypeName(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	if _, ok := asJsonMap(v); ok {
+		return "object"
+	}
+	if _, ok := asJsonSlice(v); ok {
+		return "array"
+	}
+	switch v.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	}
+	if _, err := numberRat(v); err == nil {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+var pathKeyEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// joinPathKey 生成 GetPath 格式的路径，key 含有点、中括号或者引号时使用 ["key"] 的形式，
+// 其中的 " 和 \ 转义为 \" 和 \\
+func joinPathKey(path, key string) string {
+	if key == "" || strings.ContainsAny(key, `.[]"`) {
+		return path + `["` + pathKeyEscaper.Replace(key) + `"]`
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func diffJson(path string, a, b interface{}, changes *[]JsonChange) {
+	at, bt := JsonTypeName(a), JsonTypeName(b)
+	if at != bt {
+		*changes = append(*changes, JsonChange{Kind: DiffTypeChanged, Path: path, Old: a, New: b})
+		return
+	}
+	switch at {
+	case "object":
+		am, _ := asJsonMap(a)
+		bm, _ := asJsonMap(b)
+		union := make(map[string]interface{}, len(am)+len(bm))
+		for key := range am {
+			union[key] = nil
+		}
+		for key := range bm {
+			union[key] = nil
+		}
+		for _, key := range sortedKeys(union) {
+			p := joinPathKey(path, key)
+			av, aok := am[key]
+			bv, bok := bm[key]
+			if !bok {
+				*changes = append(*changes, JsonChange{Kind: DiffRemoved, Path: p, Old: av})
+			} else if !aok {
+				*changes = append(*changes, JsonChange{Kind: DiffAdded, Path: p, New: bv})
+			} else {
+				diffJson(p, av, bv, changes)
+			}
+		}
+	case "array":
+		as, _ := asJsonSlice(a)
+		bs, _ := asJsonSlice(b)
+		for i := 0; i < len(as) || i < len(bs); i++ {
+			p := path + "[" + strconv.Itoa(i) + "]"
+			if i >= len(bs) {
+				*changes = append(*changes, JsonChange{Kind: DiffRemoved, Path: p, Old: as[i]})
+			} else if i >= len(as) {
+				*changes = append(*changes, JsonChange{Kind: DiffAdded, Path: p, New: bs[i]})
+			} else {
+				diffJson(p, as[i], bs[i], changes)
+			}
+		}
+	default:
+		if !jsonEqual(a, b) {
+			*changes = append(*changes, JsonChange{Kind: DiffModified, Path: path, Old: a, New: b})
+		}
+	}
+}
+
+// FormatJsonDiff 把变化转换为文本，每行一处变化，+ 表示增加，- 表示删除，~ 表示修改，! 表示类型改变。
+// color 为 true 时分别使用 TextGreen、TextRed、TextYellow、TextMagenta 颜色
+func FormatJsonDiff(changes []JsonChange, color bool) string {
+	var sb strings.Builder
+	for _, c := range changes {
+		path := c.Path
+		if path == "" {
+			path = "(root)"
+		}
+		var line string
+		var lineColor int
+		switch c.Kind {
+		case DiffAdded:
+			line, lineColor = "+ "+path+": "+diffValue(c.New), TextGreen
+		case DiffRemoved:
+			line, lineColor = "- "+path+": "+diffValue(c.Old), TextRed
+		case DiffModified:
+			line, lineColor = "~ "+path+": "+diffValue(c.Old)+" -> "+diffValue(c.New), TextYellow
+		default:
+			line = fmt.Sprintf("! %s: %s (%s) -> %s (%s)", path,
+				diffValue(c.Old), JsonTypeName(c.Old), diffValue(c.New), JsonTypeName(c.New))
+			lineColor = TextMagenta
+		}
+		if color {
+			line = textColor(lineColor, line)
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func diffValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
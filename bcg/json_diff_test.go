@@ -0,0 +1,29 @@
+package bcg
+
+import "testing"
+
+func TestJsonDiffPathRoundTrip(t *testing.T) {
+	// 差异路径可以直接交给 GetPath 取到新值
+	keys := []string{"plain", "a.b", "x]y", `say "hi"`, `q"]`, `back\slash`, `tail\`, `both\"]`, "[0]", ""}
+	a := JsonObject{"nested": map[string]interface{}{}}
+	b := JsonObject{"nested": map[string]interface{}{}}
+	for i, key := range keys {
+		a[key] = float64(i)
+		b[key] = float64(i + 100)
+		b["nested"].(map[string]interface{})[key] = []interface{}{key}
+	}
+	changes := JsonDiff(a, b)
+	if len(changes) != len(keys)*2 {
+		t.Fatalf("got %d changes, want %d: %v", len(changes), len(keys)*2, changes)
+	}
+	for _, c := range changes {
+		v, ok := b.GetPath(c.Path)
+		if !ok {
+			t.Errorf("%s: GetPath failed", c.Path)
+			continue
+		}
+		if binJson(t, v) != binJson(t, c.New) {
+			t.Errorf("%s: GetPath = %v, want %v", c.Path, v, c.New)
+		}
+	}
+}
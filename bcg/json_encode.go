@@ -0,0 +1,98 @@
+package bcg
+
+// json_encode 返回错误的 json 序列化函数。ToString、ToBytes、JsonToString 等函数在序列化失败时
+// （比如 NaN、chan、循环引用）只会返回空值，保存配置时会写入一个空文件，需要知道错误时使用这里的函数。
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// JsonEncodeOptions 序列化选项，nil 或者零值和 json.Marshal 的结果相同
+type JsonEncodeOptions struct {
+	// DisableHTMLEscape 不把 <、>、& 转义为 \u003c 等，配置文件和日志中更容易阅读
+	DisableHTMLEscape bool
+	// Prefix 和 Indent 见 json.MarshalIndent，Indent 为空时不换行
+	Prefix string
+	Indent string
+	// SortKeys 结构体的字段也按 key 排序输出，json.Marshal 只对 map 的 key 排序，结构体按字段定义的顺序输出
+	SortKeys bool
+}
+
+// JsonEncode 按选项序列化 v，opt 可以为 nil
+func JsonEncode(v interface{}, opt *JsonEncodeOptions) ([]byte, error) {
+	if opt == nil {
+		opt = &JsonEncodeOptions{}
+	}
+	if opt.SortKeys {
+		nv, err := jsonNormalize(v)
+		if err != nil {
+			return nil, err
+		}
+		v = nv
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(!opt.DisableHTMLEscape)
+	if opt.Prefix != "" || opt.Indent != "" {
+		enc.SetIndent(opt.Prefix, opt.Indent)
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	// Encoder 会在末尾加一个换行
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+func JsonEncodeString(v interface{}, opt *JsonEncodeOptions) (string, error) {
+	b, err := JsonEncode(v, opt)
+	return string(b), err
+}
+
+// jsonNormalize 把结构体等数据模型以外的值按 json 序列化规则转换为数据模型中的值，数字保存为 json.Number 不会丢失精度
+func jsonNormalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var nv interface{}
+	err = unmarshalUseNumber(data, &nv)
+	return nv, err
+}
+
+// jsonFormatOptions JsonToString 等函数 format 为 true 时使用 tab 缩进
+func jsonFormatOptions(format bool) *JsonEncodeOptions {
+	if format {
+		return &JsonEncodeOptions{Indent: "\t"}
+	}
+	return nil
+}
+
+func (js *JsonObject) Encode(opt *JsonEncodeOptions) ([]byte, error) {
+	return JsonEncode(*js, opt)
+}
+
+func (js *JsonObject) EncodeString(opt *JsonEncodeOptions) (string, error) {
+	return JsonEncodeString(*js, opt)
+}
+
+func (ja *JsonArray) Encode(opt *JsonEncodeOptions) ([]byte, error) {
+	return JsonEncode(*ja, opt)
+}
+
+func (ja *JsonArray) EncodeString(opt *JsonEncodeOptions) (string, error) {
+	return JsonEncodeString(*ja, opt)
+}
+
+// JsonSaveConfWith 按选项序列化并保存配置，opt 为 nil 时和 JsonSaveConf 一样使用 tab 缩进。
+// 序列化失败时不会写文件，原来的文件保持不变
+func JsonSaveConfWith(fn string, conf interface{}, opt *JsonEncodeOptions) error {
+	if opt == nil {
+		opt = jsonFormatOptions(true)
+	}
+	data, err := JsonEncode(conf, opt)
+	if err != nil {
+		return err
+	}
+	return SaveFile(fn, data)
+}
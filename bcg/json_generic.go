@@ -0,0 +1,142 @@
+package bcg
+
+// json_generic 泛型的取值函数，通过 json 数据模型把值转换为任意类型，包括结构体、切片、time.Time 和自定义类型。
+// 值的类型可以直接赋值给 T 时不做转换（JsonObject 和 JsonArray 返回的是原来的 map 和切片，不是副本），
+// 否则把值序列化后再反序列化到 T。转换失败时返回的错误包含键名或者路径，以及值的类型和目标类型。
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Get 读取 key 对应的值并转换为 T，key 不存在或者转换失败时返回错误
+//
+//	level, err := bcg.Get[int](js, "level")
+//	items, err := bcg.Get[[]Item](js, "items")
+func Get[T any](js JsonObject, key string) (T, error) {
+	var t T
+	v, ok := js[key]
+	if !ok {
+		return t, fmt.Errorf("json key %q not found", key)
+	}
+	if err := convertJson(v, &t); err != nil {
+		return t, fmt.Errorf("json key %q: %w", key, err)
+	}
+	return t, nil
+}
+
+// GetOr 和 Get 相同，但是出错时返回 def
+func GetOr[T any](js JsonObject, key string, def T) T {
+	t, err := Get[T](js, key)
+	if err != nil {
+		return def
+	}
+	return t
+}
+
+// GetPath 读取路径指定的值并转换为 T，路径格式见 JsonObject.GetPath
+func GetPath[T any](js JsonObject, path string) (T, error) {
+	var t T
+	segs, err := parsePath(path)
+	if err != nil {
+		return t, fmt.Errorf("json path %q: %w", path, err)
+	}
+	v, ok := getPath(js, segs)
+	if !ok {
+		return t, fmt.Errorf("json path %q not found", path)
+	}
+	if err = convertJson(v, &t); err != nil {
+		return t, fmt.Errorf("json path %q: %w", path, err)
+	}
+	return t, nil
+}
+
+// GetIndex 读取数组中下标 i 的值并转换为 T，i 为负数时从末尾开始计算
+func GetIndex[T any](ja JsonArray, i int) (T, error) {
+	var t T
+	idx, ok := sliceIndex(i, len(ja))
+	if !ok {
+		return t, fmt.Errorf("json index %d out of range (len %d)", i, len(ja))
+	}
+	if err := convertJson(ja[idx], &t); err != nil {
+		return t, fmt.Errorf("json index %d: %w", i, err)
+	}
+	return t, nil
+}
+
+// ConvertJson 把 json 数据模型中的值转换为 T
+func ConvertJson[T any](v interface{}) (T, error) {
+	var t T
+	err := convertJson(v, &t)
+	return t, err
+}
+
+// convertJson 把 v 转换后保存到 dst 指向的变量，dst 必须是非空指针。
+// json 的 null 转换为零值；time.Time 除了 RFC 3339 之外还接受 FormatDateTime 和 FormatDate 格式的字串（本地时区）
+func convertJson(v interface{}, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("convert json: destination must be a non-nil pointer")
+	}
+	elem := rv.Elem()
+	t := elem.Type()
+	if v == nil {
+		elem.Set(reflect.Zero(t))
+		return nil
+	}
+	vv := reflect.ValueOf(v)
+	if vv.Type().AssignableTo(t) {
+		elem.Set(vv)
+		return nil
+	}
+	if n, ok := v.(json.Number); ok {
+		if r, handled := setNumberTo(n, dst); handled {
+			if !r {
+				return fmt.Errorf("cannot convert number %s to %s", n, t)
+			}
+			return nil
+		}
+	}
+	if s, ok := v.(string); ok && t == timeType {
+		for _, layout := range []string{time.RFC3339Nano, FormatDateTime, FormatDate} {
+			if tm, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				elem.Set(reflect.ValueOf(tm))
+				return nil
+			}
+		}
+		return fmt.Errorf("cannot convert string %q to time.Time", s)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cannot convert %s to %s: %v", JsonTypeName(v), t, err)
+	}
+	ptr := reflect.New(t)
+	if err = json.Unmarshal(data, ptr.Interface()); err != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) {
+			if te.Field != "" {
+				return fmt.Errorf("cannot convert %s to %s: field %s: cannot use %s as %s", JsonTypeName(v), t, te.Field, te.Value, te.Type)
+			}
+			return fmt.Errorf("cannot convert %s to %s", jsonValueDesc(v), t)
+		}
+		return fmt.Errorf("cannot convert %s to %s: %v", JsonTypeName(v), t, err)
+	}
+	elem.Set(ptr.Elem())
+	return nil
+}
+
+// jsonValueDesc 错误信息中的值，对象和数组只显示类型
+func jsonValueDesc(v interface{}) string {
+	switch name := JsonTypeName(v); name {
+	case "object", "array":
+		return name
+	default:
+		return name + " " + diffValue(v)
+	}
+}
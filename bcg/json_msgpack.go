@@ -0,0 +1,292 @@
+package bcg
+
+// json_msgpack MessagePack 编码，数据模型和编码规则见 json_binary.go。
+// ext 类型（包括时间戳）不在 json 数据模型中，解码时返回错误。
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+type msgpackWriter struct {
+	buf []byte
+}
+
+func (w *msgpackWriter) writeNil() {
+	w.buf = append(w.buf, 0xc0)
+}
+
+func (w *msgpackWriter) writeBool(b bool) {
+	if b {
+		w.buf = append(w.buf, 0xc3)
+	} else {
+		w.buf = append(w.buf, 0xc2)
+	}
+}
+
+func (w *msgpackWriter) writeInt(i int64) {
+	switch {
+	case i >= 0:
+		w.writeUint(uint64(i))
+	case i >= -32:
+		w.buf = append(w.buf, byte(i))
+	case i >= math.MinInt8:
+		w.buf = append(w.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xd2), uint32(i))
+	default:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, 0xd3), uint64(i))
+	}
+}
+
+func (w *msgpackWriter) writeUint(u uint64) {
+	switch {
+	case u < 0x80:
+		w.buf = append(w.buf, byte(u))
+	case u <= math.MaxUint8:
+		w.buf = append(w.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xce), uint32(u))
+	default:
+		w.buf = binary.BigEndian.AppendUint64(append(w.buf, 0xcf), u)
+	}
+}
+
+func (w *msgpackWriter) writeFloat(f float64) {
+	if f32 := float32(f); float64(f32) == f {
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, 0xca), math.Float32bits(f32))
+		return
+	}
+	w.buf = binary.BigEndian.AppendUint64(append(w.buf, 0xcb), math.Float64bits(f))
+}
+
+// writeBigInt MessagePack 没有大整数类型，转换为 float64 会丢失精度，所以返回错误
+func (w *msgpackWriter) writeBigInt(b *big.Int) error {
+	return fmt.Errorf("integer %s out of msgpack range", b.String())
+}
+
+// writeHeader 输出字串、二进制数据、数组和对象的类型和长度，fix 为 0 表示没有 fix 格式
+func (w *msgpackWriter) writeHeader(n int, fix byte, fixMax int, b8, b16, b32 byte) {
+	switch {
+	case fix != 0 && n <= fixMax:
+		w.buf = append(w.buf, fix|byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		w.buf = append(w.buf, b8, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = binary.BigEndian.AppendUint16(append(w.buf, b16), uint16(n))
+	default:
+		w.buf = binary.BigEndian.AppendUint32(append(w.buf, b32), uint32(n))
+	}
+}
+
+func (w *msgpackWriter) writeString(s string) {
+	w.writeHeader(len(s), 0xa0, 31, 0xd9, 0xda, 0xdb)
+	w.buf = append(w.buf, s...)
+}
+
+func (w *msgpackWriter) writeBytes(b []byte) {
+	w.writeHeader(len(b), 0, 0, 0xc4, 0xc5, 0xc6)
+	w.buf = append(w.buf, b...)
+}
+
+func (w *msgpackWriter) writeArrayHeader(n int) {
+	w.writeHeader(n, 0x90, 15, 0, 0xdc, 0xdd)
+}
+
+func (w *msgpackWriter) writeMapHeader(n int) {
+	w.writeHeader(n, 0x80, 15, 0, 0xde, 0xdf)
+}
+
+// MsgpackMarshal 把 json 数据模型中的值编码为 MessagePack
+func MsgpackMarshal(v interface{}) ([]byte, error) {
+	w := &msgpackWriter{buf: make([]byte, 0, 256)}
+	if err := binEncode(w, v, 0); err != nil {
+		return nil, fmt.Errorf("msgpack: %v", err)
+	}
+	return w.buf, nil
+}
+
+// MsgpackUnmarshal 解码 MessagePack 数据，对象解码为 map[string]interface{}，数组解码为 []interface{}，
+// 数据末尾有多余的字节时返回错误
+func MsgpackUnmarshal(data []byte) (interface{}, error) {
+	r := &binReader{data: data}
+	v, err := r.msgpack(0)
+	if err == nil && r.pos < len(data) {
+		err = fmt.Errorf("%d extra bytes after value", len(data)-r.pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: offset %d: %v", r.pos, err)
+	}
+	return v, nil
+}
+
+func (js *JsonObject) ToMsgpack() ([]byte, error) {
+	return MsgpackMarshal(*js)
+}
+
+// ParseMsgpack 解码 MessagePack 对象，和 ParseBytes 一样合并到原来的对象
+func (js *JsonObject) ParseMsgpack(data []byte) error {
+	v, err := MsgpackUnmarshal(data)
+	if err != nil {
+		return err
+	}
+	if err = setBinObject(js, v); err != nil {
+		return fmt.Errorf("msgpack: %v", err)
+	}
+	return nil
+}
+
+func (ja *JsonArray) ToMsgpack() ([]byte, error) {
+	return MsgpackMarshal(*ja)
+}
+
+func (ja *JsonArray) ParseMsgpack(data []byte) error {
+	v, err := MsgpackUnmarshal(data)
+	if err != nil {
+		return err
+	}
+	if err = setBinArray(ja, v); err != nil {
+		return fmt.Errorf("msgpack: %v", err)
+	}
+	return nil
+}
+
+func (r *binReader) msgpack(depth int) (interface{}, error) {
+	if depth > binMaxDepth {
+		return nil, fmt.Errorf("nesting too deep")
+	}
+	b, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return float64(b), nil
+	case b >= 0xe0:
+		return float64(int8(b)), nil
+	case b >= 0xa0 && b <= 0xbf:
+		return r.msgpackString(uint64(b & 0x1f))
+	case b >= 0x90 && b <= 0x9f:
+		return r.msgpackArray(uint64(b&0x0f), depth)
+	case b >= 0x80 && b <= 0x8f:
+		return r.msgpackMap(uint64(b&0x0f), depth)
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := r.readUint(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return binUint(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		u, err := r.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		// 符号扩展
+		shift := 64 - 8*size
+		return binInt(int64(u<<shift) >> shift), nil
+	case 0xca:
+		u, err := r.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(u))), nil
+	case 0xcb:
+		u, err := r.readUint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(u), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.readUint(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.msgpackString(n)
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.readUint(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		data, err := r.readBytes(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, data...), nil
+	case 0xdc, 0xdd:
+		n, err := r.readUint(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.msgpackArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := r.readUint(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.msgpackMap(n, depth)
+	case 0xc7, 0xc8, 0xc9, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return nil, fmt.Errorf("ext type 0x%02x is not supported", b)
+	}
+	return nil, fmt.Errorf("invalid type 0x%02x", b)
+}
+
+func (r *binReader) msgpackString(n uint64) (interface{}, error) {
+	data, err := r.readBytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (r *binReader) msgpackArray(n uint64, depth int) (interface{}, error) {
+	if err := r.checkCount(n, 1); err != nil {
+		return nil, err
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := r.msgpack(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (r *binReader) msgpackMap(n uint64, depth int) (interface{}, error) {
+	if err := r.checkCount(n, 2); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, n)
+	for i := uint64(0); i < n; i++ {
+		k, err := r.msgpack(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("map key must be a string, got %s", JsonTypeName(k))
+		}
+		v, err := r.msgpack(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
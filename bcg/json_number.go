@@ -0,0 +1,225 @@
+package bcg
+
+// json_number 提供不损失精度的数字处理。ParseString 把数字解析为 float64，超过 2^53 的整数会损失精度，
+// 使用 ParseStringUseNumber 等函数解析时数字保存为 json.Number，GetInt64、GetUint64、GetBigInt 等函数
+// 可以精确地取回数字，数字有小数部分或者超出范围时返回错误，而不是像 float64 转换那样悄悄截断。
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+func unmarshalUseNumber(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// ParseStringUseNumber 和 ParseString 相同，但是数字保存为 json.Number，不会损失精度
+func (js *JsonObject) ParseStringUseNumber(str string) error {
+	return unmarshalUseNumber([]byte(str), js)
+}
+func (js *JsonObject) ParseBytesUseNumber(b []byte) error {
+	return unmarshalUseNumber(b, js)
+}
+func (ja *JsonArray) ParseStringUseNumber(str string) error {
+	return unmarshalUseNumber([]byte(str), ja)
+}
+func (ja *JsonArray) ParseBytesUseNumber(b []byte) error {
+	return unmarshalUseNumber(b, ja)
+}
+
+// numberRat 把 json 数字转换为 big.Rat，支持 json.Number、float64 和各种整数类型
+func numberRat(v interface{}) (*big.Rat, error) {
+	r := new(big.Rat)
+	switch n := v.(type) {
+	case json.Number:
+		if _, ok := r.SetString(string(n)); !ok {
+			return nil, fmt.Errorf("invalid number %q", string(n))
+		}
+	case float64, float32:
+		// 使用最短的十进制表示，也就是 json 字串中原来的数字，而不是浮点数的精确二进制值
+		f := reflect.ValueOf(n).Float()
+		if _, ok := r.SetString(strconv.FormatFloat(f, 'g', -1, 64)); !ok {
+			return nil, fmt.Errorf("invalid number %v", f)
+		}
+	case int, int8, int16, int32, int64:
+		r.SetInt64(reflect.ValueOf(n).Int())
+	case uint, uint8, uint16, uint32, uint64:
+		r.SetUint64(reflect.ValueOf(n).Uint())
+	default:
+		return nil, fmt.Errorf("%T is not a number", v)
+	}
+	return r, nil
+}
+
+func numberBigInt(v interface{}) (*big.Int, error) {
+	r, err := numberRat(v)
+	if err != nil {
+		return nil, err
+	}
+	if !r.IsInt() {
+		return nil, fmt.Errorf("number %s is not an integer", decimalString(r))
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+func numberInt64(v interface{}) (int64, error) {
+	n, err := numberBigInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if !n.IsInt64() {
+		return 0, fmt.Errorf("number %s overflows int64", n)
+	}
+	return n.Int64(), nil
+}
+
+func numberUint64(v interface{}) (uint64, error) {
+	n, err := numberBigInt(v)
+	if err != nil {
+		return 0, err
+	}
+	if !n.IsUint64() {
+		return 0, fmt.Errorf("number %s overflows uint64", n)
+	}
+	return n.Uint64(), nil
+}
+
+// decimalString 返回 r 的十进制表示，没有指数部分，也没有多余的 0。json 的数字都是有限小数，所以结果是精确的
+func decimalString(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	// 分母只含有因子 2 和 5 时，小数位数等于两个因子的个数中较大的一个
+	d := new(big.Int).Set(r.Denom())
+	two, five, rem := big.NewInt(2), big.NewInt(5), new(big.Int)
+	prec := 0
+	for c2, c5 := 0, 0; d.Cmp(big.NewInt(1)) != 0; {
+		if rem.Mod(d, two).Sign() == 0 {
+			d.Quo(d, two)
+			c2++
+		} else if rem.Mod(d, five).Sign() == 0 {
+			d.Quo(d, five)
+			c5++
+		} else {
+			return r.FloatString(20)
+		}
+		if c2 > prec {
+			prec = c2
+		}
+		if c5 > prec {
+			prec = c5
+		}
+	}
+	return r.FloatString(prec)
+}
+
+// GetNumber 返回 key 对应的 json.Number，值是 float64 或者其它数字类型时也会被转换
+func (js *JsonObject) GetNumber(key string) (json.Number, bool) {
+	switch val := (*js)[key].(type) {
+	case json.Number:
+		return val, true
+	case float64:
+		return json.Number(strconv.FormatFloat(val, 'g', -1, 64)), true
+	default:
+		r, err := numberRat(val)
+		if err != nil {
+			return "", false
+		}
+		return json.Number(decimalString(r)), true
+	}
+}
+
+// GetInt64 精确读取整数，值不是数字、有小数部分或者超出 int64 范围时返回错误
+func (js *JsonObject) GetInt64(key string) (int64, error) {
+	v, err := numberInt64((*js)[key])
+	if err != nil {
+		return 0, fmt.Errorf("json key %q: %v", key, err)
+	}
+	return v, nil
+}
+
+// GetUint64 精确读取无符号整数，值不是数字、有小数部分或者超出 uint64 范围时返回错误
+func (js *JsonObject) GetUint64(key string) (uint64, error) {
+	v, err := numberUint64((*js)[key])
+	if err != nil {
+		return 0, fmt.Errorf("json key %q: %v", key, err)
+	}
+	return v, nil
+}
+
+// GetBigInt 读取任意大小的整数，值不是数字或者有小数部分时返回错误
+func (js *JsonObject) GetBigInt(key string) (*big.Int, error) {
+	v, err := numberBigInt((*js)[key])
+	if err != nil {
+		return nil, fmt.Errorf("json key %q: %v", key, err)
+	}
+	return v, nil
+}
+
+// GetDecimalString 返回数字的十进制字串，没有指数部分，比如 1.5e3 返回 "1500"，可以用于金额等需要精确小数的场合
+func (js *JsonObject) GetDecimalString(key string) (string, error) {
+	r, err := numberRat((*js)[key])
+	if err != nil {
+		return "", fmt.Errorf("json key %q: %v", key, err)
+	}
+	return decimalString(r), nil
+}
+
+// setNumberTo 把 json.Number 精确地设置到 v 指向的变量，v 不是数字类型的指针时 handled 为 false
+func setNumberTo(n json.Number, v interface{}) (r bool, handled bool) {
+	switch p := v.(type) {
+	case *json.Number:
+		*p = n
+		return true, true
+	case *big.Int:
+		bi, err := numberBigInt(n)
+		if err != nil {
+			return false, true
+		}
+		p.Set(bi)
+		return true, true
+	case *float64:
+		f, err := n.Float64()
+		if err != nil {
+			return false, true
+		}
+		*p = f
+		return true, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return false, false
+	}
+	elem := rv.Elem()
+	switch elem.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := numberInt64(n)
+		if err != nil || elem.OverflowInt(i) {
+			return false, true
+		}
+		elem.SetInt(i)
+		return true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := numberUint64(n)
+		if err != nil || elem.OverflowUint(u) {
+			return false, true
+		}
+		elem.SetUint(u)
+		return true, true
+	}
+	return false, false
+}
@@ -0,0 +1,433 @@
+package bcg
+
+// json_patch 实现 RFC 7386 JSON Merge Patch 和 RFC 6902 JSON Patch。
+// MergePatch 适合管理后台提交的部分配置，值为 null 表示删除这个 key；
+// ApplyPatch 按顺序执行 add/remove/replace/move/copy/test 操作，任何一个操作失败时对象保持不变。
+// CreateMergePatch 和 CreatePatch 生成从一个对象变为另一个对象的补丁。
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MergePatch 按照 RFC 7386 把 patch 合并到 js，patch 中值为 nil 的 key 会被删除，值为对象时递归合并，其它值直接替换
+func (js *JsonObject) MergePatch(patch JsonObject) {
+	if *js == nil {
+		*js = JsonObject{}
+	}
+	mergePatch(*js, patch)
+}
+
+// MergePatchBytes 解析 json 字串形式的 merge patch 并合并，patch 不是对象时返回错误
+func (js *JsonObject) MergePatchBytes(b []byte) error {
+	patch := NewJsonObject()
+	if err := patch.ParseBytes(b); err != nil {
+		return err
+	}
+	js.MergePatch(patch)
+	return nil
+}
+
+func mergePatch(target, patch map[string]interface{}) {
+	for key, pv := range patch {
+		if pv == nil {
+			delete(target, key)
+			continue
+		}
+		pm, ok := asJsonMap(pv)
+		if !ok {
+			target[key] = deepCopyJson(pv)
+			continue
+		}
+		tm, ok := asJsonMap(target[key])
+		if !ok {
+			tm = map[string]interface{}{}
+		}
+		mergePatch(tm, pm)
+		target[key] = tm
+	}
+}
+
+// CreateMergePatch 生成把 src 变为 dst 的 merge patch。merge patch 不能表示把值设置为 null，dst 中值为 null 的 key 会被当作删除
+func CreateMergePatch(src, dst JsonObject) JsonObject {
+	patch := NewJsonObject()
+	for key := range src {
+		if _, ok := dst[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for key, dv := range dst {
+		sv, ok := src[key]
+		if !ok {
+			patch[key] = deepCopyJson(dv)
+			continue
+		}
+		sm, sok := asJsonMap(sv)
+		dm, dok := asJsonMap(dv)
+		if sok && dok {
+			if sub := CreateMergePatch(sm, dm); len(sub) > 0 {
+				patch[key] = sub
+			}
+		} else if !jsonEqual(sv, dv) {
+			patch[key] = deepCopyJson(dv)
+		}
+	}
+	return patch
+}
+
+// ApplyPatch 按照 RFC 6902 执行 patch 中的操作，任何一个操作失败（包括 test 不相等）都会返回错误，并且 js 保持不变
+func (js *JsonObject) ApplyPatch(patch JsonArray) error {
+	var doc interface{} = deepCopyJson(map[string]interface{}(*js))
+	for i, item := range patch {
+		op, ok := asJsonMap(item)
+		if !ok {
+			return fmt.Errorf("json patch op %d: not an object", i)
+		}
+		var err error
+		doc, err = applyPatchOp(doc, op)
+		if err != nil {
+			return fmt.Errorf("json patch op %d (%v %v): %v", i, op["op"], op["path"], err)
+		}
+	}
+	m, ok := asJsonMap(doc)
+	if !ok {
+		return fmt.Errorf("json patch: result is not an object")
+	}
+	*js = m
+	return nil
+}
+
+// ApplyPatchBytes 解析 json 字串形式的 patch 并执行
+func (js *JsonObject) ApplyPatchBytes(b []byte) error {
+	patch := NewJsonArray()
+	if err := patch.ParseBytes(b); err != nil {
+		return err
+	}
+	return js.ApplyPatch(patch)
+}
+
+func applyPatchOp(doc interface{}, op map[string]interface{}) (interface{}, error) {
+	name, _ := op["op"].(string)
+	path, ok := op["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing path")
+	}
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	var from []string
+	if name == "move" || name == "copy" {
+		fs, ok := op["from"].(string)
+		if !ok {
+			return nil, fmt.Errorf("missing from")
+		}
+		if from, err = parsePointer(fs); err != nil {
+			return nil, err
+		}
+	}
+	value, hasValue := op["value"]
+	if !hasValue && (name == "add" || name == "replace" || name == "test") {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	switch name {
+	case "add":
+		return pointerAdd(doc, tokens, deepCopyJson(value))
+	case "remove":
+		doc, _, err = pointerRemove(doc, tokens)
+		return doc, err
+	case "replace":
+		if _, err = pointerGet(doc, tokens); err != nil {
+			return nil, err
+		}
+		if len(tokens) == 0 {
+			return deepCopyJson(value), nil
+		}
+		doc, _, err = pointerRemove(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, tokens, deepCopyJson(value))
+	case "move":
+		if len(from) < len(tokens) && isPointerPrefix(from, tokens) {
+			return nil, fmt.Errorf("can not move a value into itself")
+		}
+		var v interface{}
+		doc, v, err = pointerRemove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, tokens, v)
+	case "copy":
+		v, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, tokens, deepCopyJson(v))
+	case "test":
+		v, err := pointerGet(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(v, value) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", name)
+}
+
+// CreatePatch 生成把 src 变为 dst 的 JSON Patch，对象按 key 比较，长度相同的数组按下标比较，长度不同的数组整体替换
+func CreatePatch(src, dst JsonObject) JsonArray {
+	ops := NewJsonArray()
+	diffPatch("", map[string]interface{}(src), map[string]interface{}(dst), &ops)
+	return ops
+}
+
+func diffPatch(path string, a, b interface{}, ops *JsonArray) {
+	am, aok := asJsonMap(a)
+	bm, bok := asJsonMap(b)
+	if aok && bok {
+		for _, key := range sortedKeys(am) {
+			p := path + "/" + escapePointer(key)
+			if bv, ok := bm[key]; ok {
+				diffPatch(p, am[key], bv, ops)
+			} else {
+				ops.Append(JsonObject{"op": "remove", "path": p})
+			}
+		}
+		for _, key := range sortedKeys(bm) {
+			if _, ok := am[key]; !ok {
+				p := path + "/" + escapePointer(key)
+				ops.Append(JsonObject{"op": "add", "path": p, "value": deepCopyJson(bm[key])})
+			}
+		}
+		return
+	}
+	as, aok := asJsonSlice(a)
+	bs, bok := asJsonSlice(b)
+	if aok && bok && len(as) == len(bs) {
+		for i := range as {
+			diffPatch(path+"/"+strconv.Itoa(i), as[i], bs[i], ops)
+		}
+		return
+	}
+	if !jsonEqual(a, b) {
+		ops.Append(JsonObject{"op": "replace", "path": path, "value": deepCopyJson(b)})
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parsePointer 解析 RFC 6901 JSON Pointer，空字串表示整个文档
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return []string{}, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("json pointer %q must start with /", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func isPointerPrefix(prefix, tokens []string) bool {
+	for i := range prefix {
+		if prefix[i] != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pointerIndex 解析数组下标，不允许前导 0 和负数，allowEnd 为 true 时 "-" 和 length 都表示数组末尾
+func pointerIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.Trim(token, "0123456789") != "" {
+		return 0, fmt.Errorf("bad array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i > length || (i == length && !allowEnd) {
+		return 0, fmt.Errorf("array index %q out of range", token)
+	}
+	return i, nil
+}
+
+func pointerGet(doc interface{}, tokens []string) (interface{}, error) {
+	cur := doc
+	for _, t := range tokens {
+		if m, ok := asJsonMap(cur); ok {
+			v, ok := m[t]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found", t)
+			}
+			cur = v
+		} else if a, ok := asJsonSlice(cur); ok {
+			i, err := pointerIndex(t, len(a), false)
+			if err != nil {
+				return nil, err
+			}
+			cur = a[i]
+		} else {
+			return nil, fmt.Errorf("can not get %q from a scalar value", t)
+		}
+	}
+	return cur, nil
+}
+
+// pointerUpdate 找到 tokens 最后一项所在的容器并调用 fn 修改，返回修改后的 doc，因为数组修改后可能是一个新的 slice
+func pointerUpdate(doc interface{}, tokens []string, fn func(parent interface{}, last string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	t := tokens[0]
+	if m, ok := asJsonMap(doc); ok {
+		child, ok := m[t]
+		if !ok {
+			return nil, fmt.Errorf("key %q not found", t)
+		}
+		child, err := pointerUpdate(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		m[t] = child
+		return doc, nil
+	}
+	if a, ok := asJsonSlice(doc); ok {
+		i, err := pointerIndex(t, len(a), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := pointerUpdate(a[i], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = child
+		return a, nil
+	}
+	return nil, fmt.Errorf("can not get %q from a scalar value", t)
+}
+
+func pointerAdd(doc interface{}, tokens []string, v interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	return pointerUpdate(doc, tokens, func(parent interface{}, last string) (interface{}, error) {
+		if m, ok := asJsonMap(parent); ok {
+			m[last] = v
+			return parent, nil
+		}
+		if a, ok := asJsonSlice(parent); ok {
+			i, err := pointerIndex(last, len(a), true)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, nil)
+			copy(a[i+1:], a[i:])
+			a[i] = v
+			return a, nil
+		}
+		return nil, fmt.Errorf("can not add %q to a scalar value", last)
+	})
+}
+
+func pointerRemove(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("can not remove the whole document")
+	}
+	var removed interface{}
+	doc, err := pointerUpdate(doc, tokens, func(parent interface{}, last string) (interface{}, error) {
+		if m, ok := asJsonMap(parent); ok {
+			v, ok := m[last]
+			if !ok {
+				return nil, fmt.Errorf("key %q not found", last)
+			}
+			removed = v
+			delete(m, last)
+			return parent, nil
+		}
+		if a, ok := asJsonSlice(parent); ok {
+			i, err := pointerIndex(last, len(a), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = a[i]
+			return append(a[:i:i], a[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("can not remove %q from a scalar value", last)
+	})
+	return doc, removed, err
+}
+
+// deepCopyJson 复制 json 数据模型中的值，对象和数组会被递归复制，JsonObject 和 JsonArray 被转换为 map 和 slice
+func deepCopyJson(v interface{}) interface{} {
+	if m, ok := asJsonMap(v); ok {
+		c := make(map[string]interface{}, len(m))
+		for key, mv := range m {
+			c[key] = deepCopyJson(mv)
+		}
+		return c
+	}
+	if a, ok := asJsonSlice(v); ok {
+		c := make([]interface{}, len(a))
+		for i, av := range a {
+			c[i] = deepCopyJson(av)
+		}
+		return c
+	}
+	return v
+}
+
+// jsonEqual 按照 json 的语义比较两个值，数字按数值比较，所以 float64 的 1 和 json.Number 的 "1.0" 相等
+func jsonEqual(a, b interface{}) bool {
+	if am, ok := asJsonMap(a); ok {
+		bm, ok := asJsonMap(b)
+		if !ok || len(am) != len(bm) {
+			return false
+		}
+		for key, av := range am {
+			bv, ok := bm[key]
+			if !ok || !jsonEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	if as, ok := asJsonSlice(a); ok {
+		bs, ok := asJsonSlice(b)
+		if !ok || len(as) != len(bs) {
+			return false
+		}
+		for i := range as {
+			if !jsonEqual(as[i], bs[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if ar, err := numberRat(a); err == nil {
+		br, err := numberRat(b)
+		return err == nil && ar.Cmp(br) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,322 @@
+package bcg
+
+// json_path 通过路径读写 JsonObject 中嵌套的值，路径由点分隔的 key 和中括号中的下标组成，比如 data.items[3].price。
+// 下标可以是负数，-1 表示最后一个元素。key 中含有点或者中括号时，可以写成 data["a.b"]，
+// 引号中的 \" 和 \\ 分别表示 " 和 \。
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type pathSeg struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (seg pathSeg) String() string {
+	if seg.isIndex {
+		return "[" + strconv.Itoa(seg.index) + "]"
+	}
+	return seg.key
+}
+
+func parsePath(path string) ([]pathSeg, error) {
+	segs := make([]pathSeg, 0, 4)
+	i := 0
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			if i == 0 || i == len(path)-1 || path[i+1] == '.' {
+				return nil, fmt.Errorf("json path %q: empty key at %d", path, i)
+			}
+			i++
+		case '[':
+			if i+1 < len(path) && path[i+1] == '"' {
+				key, next, err := parseQuotedKey(path, i+1)
+				if err != nil {
+					return nil, err
+				}
+				segs = append(segs, pathSeg{key: key})
+				i = next
+				break
+			}
+			end := strings.IndexByte(path[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("json path %q: missing ] at %d", path, i)
+			}
+			inner := path[i+1 : i+end]
+			n, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("json path %q: bad index %q", path, inner)
+			}
+			segs = append(segs, pathSeg{index: n, isIndex: true})
+			i += end + 1
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end == -1 {
+				end = len(path) - i
+			}
+			segs = append(segs, pathSeg{key: path[i : i+end]})
+			i += end
+		}
+	}
+	if len(segs) == 0 {
+		return nil, fmt.Errorf("json path %q: empty path", path)
+	}
+	return segs, nil
+}
+
+// parseQuotedKey 解析从 start 处的引号到第一个未转义的 "] 之间的 key，返回 key 和 ] 之后的位置
+func parseQuotedKey(path string, start int) (string, int, error) {
+	var sb strings.Builder
+	for i := start + 1; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\\':
+			if i+1 < len(path) && (path[i+1] == '"' || path[i+1] == '\\') {
+				i++
+				sb.WriteByte(path[i])
+			} else {
+				sb.WriteByte(c)
+			}
+		case '"':
+			if i+1 < len(path) && path[i+1] == ']' {
+				return sb.String(), i + 2, nil
+			}
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("json path %q: missing \"] at %d", path, start)
+}
+
+// asJsonMap 把 JsonObject 和 map[string]interface{} 统一为 map
+func asJsonMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case JsonObject:
+		return m, true
+	case *JsonObject:
+		if m == nil {
+			return nil, false
+		}
+		return *m, true
+	}
+	return nil, false
+}
+
+// asJsonSlice 把 JsonArray 和 []interface{} 统一为 slice
+func asJsonSlice(v interface{}) ([]interface{}, bool) {
+	switch a := v.(type) {
+	case []interface{}:
+		return a, true
+	case JsonArray:
+		return a, true
+	case *JsonArray:
+		if a == nil {
+			return nil, false
+		}
+		return *a, true
+	}
+	return nil, false
+}
+
+func sliceIndex(index, length int) (int, bool) {
+	if index < 0 {
+		index += length
+	}
+	return index, index >= 0 && index < length
+}
+
+func getPath(root interface{}, segs []pathSeg) (interface{}, bool) {
+	cur := root
+	for _, seg := range segs {
+		if seg.isIndex {
+			a, ok := asJsonSlice(cur)
+			if !ok {
+				return nil, false
+			}
+			i, ok := sliceIndex(seg.index, len(a))
+			if !ok {
+				return nil, false
+			}
+			cur = a[i]
+		} else {
+			m, ok := asJsonMap(cur)
+			if !ok {
+				return nil, false
+			}
+			cur, ok = m[seg.key]
+			if !ok {
+				return nil, false
+			}
+		}
+	}
+	return cur, true
+}
+
+// setPath 设置 container 中 segs 指定的值，返回设置后的 container，因为 slice 追加元素后可能是一个新的 slice
+func setPath(container interface{}, segs []pathSeg, v interface{}) (interface{}, error) {
+	seg := segs[0]
+	if seg.isIndex {
+		if container == nil {
+			container = []interface{}{}
+		}
+		a, ok := asJsonSlice(container)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an array", seg)
+		}
+		i, ok := sliceIndex(seg.index, len(a))
+		if !ok {
+			if seg.index != len(a) {
+				return nil, fmt.Errorf("%s: index out of range", seg)
+			}
+			a = append(a, nil)
+			i = len(a) - 1
+		}
+		if len(segs) == 1 {
+			a[i] = v
+			return a, nil
+		}
+		child, err := setPath(a[i], segs[1:], v)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = child
+		return a, nil
+	}
+
+	if container == nil {
+		container = map[string]interface{}{}
+	}
+	m, ok := asJsonMap(container)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an object", seg)
+	}
+	if len(segs) == 1 {
+		m[seg.key] = v
+		return container, nil
+	}
+	child, err := setPath(m[seg.key], segs[1:], v)
+	if err != nil {
+		return nil, err
+	}
+	m[seg.key] = child
+	return container, nil
+}
+
+// deletePath 删除 container 中 segs 指定的值，返回删除后的 container
+func deletePath(container interface{}, segs []pathSeg) (interface{}, bool) {
+	seg := segs[0]
+	if seg.isIndex {
+		a, ok := asJsonSlice(container)
+		if !ok {
+			return container, false
+		}
+		i, ok := sliceIndex(seg.index, len(a))
+		if !ok {
+			return container, false
+		}
+		if len(segs) == 1 {
+			return append(a[:i:i], a[i+1:]...), true
+		}
+		child, ok := deletePath(a[i], segs[1:])
+		a[i] = child
+		return a, ok
+	}
+
+	m, ok := asJsonMap(container)
+	if !ok {
+		return container, false
+	}
+	v, ok := m[seg.key]
+	if !ok {
+		return container, false
+	}
+	if len(segs) == 1 {
+		delete(m, seg.key)
+		return container, true
+	}
+	child, ok := deletePath(v, segs[1:])
+	m[seg.key] = child
+	return container, ok
+}
+
+// GetPath 读取路径指定的值，路径不存在或者格式错误时返回 false
+func (js *JsonObject) GetPath(path string) (interface{}, bool) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	return getPath(*js, segs)
+}
+
+// SetPath 设置路径指定的值，路径中不存在的对象会被自动创建，数组的下标等于数组长度时会追加一个元素。
+// 路径经过的值类型不对（比如对字串使用下标）或者下标越界时返回错误
+func (js *JsonObject) SetPath(path string, v interface{}) error {
+	segs, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if segs[0].isIndex {
+		return fmt.Errorf("json path %q: JsonObject can not be indexed", path)
+	}
+	if *js == nil {
+		*js = JsonObject{}
+	}
+	_, err = setPath(*js, segs, v)
+	if err != nil {
+		return fmt.Errorf("json path %q: %v", path, err)
+	}
+	return nil
+}
+
+// DeletePath 删除路径指定的值，如果是数组元素，后面的元素会前移，路径不存在时返回 false
+func (js *JsonObject) DeletePath(path string) bool {
+	segs, err := parsePath(path)
+	if err != nil {
+		return false
+	}
+	_, ok := deletePath(*js, segs)
+	return ok
+}
+
+func (js *JsonObject) GetPathString(path string) (string, bool) {
+	v, _ := js.GetPath(path)
+	s, ok := v.(string)
+	return s, ok
+}
+func (js *JsonObject) GetPathFloat64(path string) (float64, bool) {
+	v, _ := js.GetPath(path)
+	switch f := v.(type) {
+	case float64:
+		return f, true
+	case json.Number:
+		n, err := f.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
+func (js *JsonObject) GetPathBool(path string) (bool, bool) {
+	v, _ := js.GetPath(path)
+	b, ok := v.(bool)
+	return b, ok
+}
+func (js *JsonObject) GetPathJson(path string) (JsonObject, bool) {
+	v, _ := js.GetPath(path)
+	m, ok := asJsonMap(v)
+	return m, ok
+}
+func (js *JsonObject) GetPathArray(path string) ([]interface{}, bool) {
+	v, _ := js.GetPath(path)
+	a, ok := asJsonSlice(v)
+	if !ok {
+		return []interface{}{}, false
+	}
+	return a, true
+}
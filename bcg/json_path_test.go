@@ -0,0 +1,51 @@
+package bcg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePathQuotedKey(t *testing.T) {
+	cases := []struct {
+		path string
+		want []pathSeg
+	}{
+		{`a["x]y"]`, []pathSeg{{key: "a"}, {key: "x]y"}}},
+		{`a["x.y"].b`, []pathSeg{{key: "a"}, {key: "x.y"}, {key: "b"}}},
+		{`["say \"hi\""][0]`, []pathSeg{{key: `say "hi"`}, {index: 0, isIndex: true}}},
+		{`["a\\"]`, []pathSeg{{key: `a\`}}},
+		{`["a\\\"]"]`, []pathSeg{{key: `a\"]`}}},
+		{`["a"b"]`, []pathSeg{{key: `a"b`}}},
+		{`[""]`, []pathSeg{{key: ""}}},
+	}
+	for _, c := range cases {
+		segs, err := parsePath(c.path)
+		if err != nil {
+			t.Errorf("%s: %v", c.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(segs, c.want) {
+			t.Errorf("%s: got %v, want %v", c.path, segs, c.want)
+		}
+	}
+
+	// 缺少结束的 "] 时返回错误
+	for _, path := range []string{`a["x`, `a["x"`, `a["x\"]`} {
+		if _, err := parsePath(path); err == nil {
+			t.Errorf("%s: expected error", path)
+		}
+	}
+}
+
+func TestGetPathQuotedKey(t *testing.T) {
+	js := JsonObject{"a": map[string]interface{}{"x]y": 1.0, `q"`: []interface{}{"v"}, "d.e": true}}
+	if v, ok := js.GetPath(`a["x]y"]`); !ok || v != 1.0 {
+		t.Errorf(`a["x]y"] = %v, %v`, v, ok)
+	}
+	if v, ok := js.GetPath(`a["q\""][0]`); !ok || v != "v" {
+		t.Errorf(`a["q\""][0] = %v, %v`, v, ok)
+	}
+	if v, ok := js.GetPath(`a["d.e"]`); !ok || v != true {
+		t.Errorf(`a["d.e"] = %v, %v`, v, ok)
+	}
+}
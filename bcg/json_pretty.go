@@ -0,0 +1,172 @@
+package bcg
+
+// json_pretty 带颜色的 json 格式化输出，用于在控制台查看数据。
+// key 使用蓝色，字串绿色，数字青色，bool 黄色，null 紫色。
+// 超长的字串和数组会被截断，后面显示 "...N more"，所以输出的不一定是合法的 json，只用于显示。
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type JsonPrettyOptions struct {
+	// Indent 缩进字串，为空时使用两个空格
+	Indent string
+	// MaxString 字串最多显示的字符数，MaxArray 数组最多显示的元素数，0 表示不限制
+	MaxString int
+	MaxArray  int
+	// Color 是否输出 ANSI 颜色
+	Color bool
+}
+
+// DefaultJsonPrettyOptions JsonPretty 的 opt 为 nil 时和 LogJson 使用的选项
+var DefaultJsonPrettyOptions = JsonPrettyOptions{Indent: "  ", MaxString: 200, MaxArray: 20, Color: true}
+
+// JsonPretty 格式化 v，v 可以是 JsonObject、JsonArray 或者任何可以序列化为 json 的值，序列化失败时返回错误信息
+func JsonPretty(v interface{}, opt *JsonPrettyOptions) string {
+	if opt == nil {
+		opt = &DefaultJsonPrettyOptions
+	}
+	nv, err := jsonNormalize(v)
+	if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	p := &jsonPrinter{opt: opt, indent: opt.Indent}
+	if p.indent == "" {
+		p.indent = "  "
+	}
+	p.value(nv, 0)
+	return p.sb.String()
+}
+
+type jsonPrinter struct {
+	sb     strings.Builder
+	opt    *JsonPrettyOptions
+	indent string
+}
+
+func (p *jsonPrinter) color(color int, str string) {
+	if p.opt.Color {
+		str = textColor(color, str)
+	}
+	p.sb.WriteString(str)
+}
+
+func (p *jsonPrinter) newline(depth int) {
+	p.sb.WriteByte('\n')
+	p.sb.WriteString(strings.Repeat(p.indent, depth))
+}
+
+func (p *jsonPrinter) value(v interface{}, depth int) {
+	switch x := v.(type) {
+	case nil:
+		p.color(TextMagenta, "null")
+	case bool:
+		p.color(TextYellow, strconv.FormatBool(x))
+	case json.Number:
+		p.color(TextCyan, x.String())
+	case string:
+		p.str(x)
+	case []interface{}:
+		p.array(x, depth)
+	case map[string]interface{}:
+		p.object(x, depth)
+	default:
+		p.sb.WriteString(fmt.Sprint(v))
+	}
+}
+
+func (p *jsonPrinter) str(s string) {
+	more := 0
+	if p.opt.MaxString > 0 {
+		if n := utf8.RuneCountInString(s); n > p.opt.MaxString {
+			more = n - p.opt.MaxString
+			s = string([]rune(s)[:p.opt.MaxString])
+		}
+	}
+	q, _ := JsonEncodeString(s, &JsonEncodeOptions{DisableHTMLEscape: true})
+	p.color(TextGreen, q)
+	if more > 0 {
+		p.sb.WriteString(fmt.Sprintf(" ...%d more", more))
+	}
+}
+
+func (p *jsonPrinter) array(a []interface{}, depth int) {
+	if len(a) == 0 {
+		p.sb.WriteString("[]")
+		return
+	}
+	n := len(a)
+	if p.opt.MaxArray > 0 && n > p.opt.MaxArray {
+		n = p.opt.MaxArray
+	}
+	p.sb.WriteByte('[')
+	for i := 0; i < n; i++ {
+		p.newline(depth + 1)
+		p.value(a[i], depth+1)
+		if i < len(a)-1 {
+			p.sb.WriteByte(',')
+		}
+	}
+	if n < len(a) {
+		p.newline(depth + 1)
+		p.sb.WriteString(fmt.Sprintf("...%d more", len(a)-n))
+	}
+	p.newline(depth)
+	p.sb.WriteByte(']')
+}
+
+func (p *jsonPrinter) object(m map[string]interface{}, depth int) {
+	if len(m) == 0 {
+		p.sb.WriteString("{}")
+		return
+	}
+	p.sb.WriteByte('{')
+	keys := sortedKeys(m)
+	for i, k := range keys {
+		p.newline(depth + 1)
+		q, _ := JsonEncodeString(k, &JsonEncodeOptions{DisableHTMLEscape: true})
+		p.color(TextBlue, q)
+		p.sb.WriteString(": ")
+		p.value(m[k], depth+1)
+		if i < len(keys)-1 {
+			p.sb.WriteByte(',')
+		}
+	}
+	p.newline(depth)
+	p.sb.WriteByte('}')
+}
+
+// LogJson 在 title 后面输出格式化的 v，控制台中 title 使用 color 颜色，json 使用 JsonPretty 的颜色，
+// 保存到日志数据库的是没有颜色的文本
+func LogJson(color int, title string, v interface{}) {
+	arr := getTrace()
+	logJson(title, v, arr[6], color)
+}
+
+func logJson(title string, v interface{}, trace string, color int) {
+	plainOpt := DefaultJsonPrettyOptions
+	plainOpt.Color = false
+	plain := JsonPretty(v, &plainOpt)
+	if filterLog(title) || filterLog(plain) {
+		return
+	}
+	countLog(trace, color)
+	if logParam.LogDb != nil && logParam.SaveToLog {
+		//只显示文件名
+		short := trace
+		if pos := strings.LastIndex(short, "/"); pos != -1 {
+			short = short[pos+1:]
+		}
+		saveLog(title+"\n"+plain, short, color)
+	}
+	if logParam.ShowOnConsole {
+		ts := time.Now().Format("15:04:05")
+		fmt.Println(textColor(color, ts+" "+trace+" "+title))
+		fmt.Println(JsonPretty(v, nil))
+	}
+}
@@ -0,0 +1,765 @@
+package bcg
+
+// json_query 实现 RFC 9535 JSONPath 查询，比如 $.players[?@.level >= 10].name、$..price、$.items[-3:]。
+// 支持 child（.name、['name']、[0]）、通配符（*、[*]）、递归下降（..）、切片（[start:end:step]）、
+// 多个选择器（['a','b']、[0,2]）以及过滤表达式（比较运算 == != < <= > >=，逻辑运算 && || !，括号和存在性测试）。
+// 过滤表达式中的函数扩展（length、match 等）没有实现。
+// 查询结果包含匹配的值和 RFC 9535 规定的规范化路径，比如 $['players'][0]['name']。
+// 对象的成员按 key 排序遍历，所以结果的顺序是确定的。
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type JsonPathMatch struct {
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// JsonPathExpr 编译后的 JSONPath 查询，可以重复使用，并且可以在多个 goroutine 中同时使用
+type JsonPathExpr struct {
+	query    string
+	segments []jpSegment
+}
+
+type jpSegment struct {
+	descendant bool
+	selectors  []jpSelector
+}
+
+const (
+	jpName = iota
+	jpWildcard
+	jpIndex
+	jpSlice
+	jpFilter
+)
+
+type jpSelector struct {
+	kind   int
+	name   string
+	index  int
+	slice  [3]*int
+	filter jpExpr
+}
+
+// jpExpr 过滤表达式的语法树
+type jpExpr interface {
+	test(root, cur interface{}) bool
+}
+
+// CompileJsonPath 编译 JSONPath 查询，语法错误时返回错误
+func CompileJsonPath(query string) (*JsonPathExpr, error) {
+	p := &jpParser{src: query}
+	if !p.consume("$") {
+		return nil, p.errorf("query must start with $")
+	}
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return &JsonPathExpr{query: query, segments: segments}, nil
+}
+
+// JsonPathQuery 编译并执行 JSONPath 查询，root 可以是 JsonObject、JsonArray 或者任何 json 数据模型中的值
+func JsonPathQuery(root interface{}, query string) ([]JsonPathMatch, error) {
+	expr, err := CompileJsonPath(query)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Query(root), nil
+}
+
+func (js *JsonObject) Query(query string) ([]JsonPathMatch, error) {
+	return JsonPathQuery(*js, query)
+}
+func (ja *JsonArray) Query(query string) ([]JsonPathMatch, error) {
+	return JsonPathQuery(*ja, query)
+}
+
+func (e *JsonPathExpr) String() string {
+	return e.query
+}
+
+// Query 执行查询，没有匹配时返回空数组
+func (e *JsonPathExpr) Query(root interface{}) []JsonPathMatch {
+	nodes := evalSegments(root, []JsonPathMatch{{Path: "$", Value: root}}, e.segments)
+	return nodes
+}
+
+// Values 执行查询并只返回匹配的值
+func (e *JsonPathExpr) Values(root interface{}) []interface{} {
+	nodes := e.Query(root)
+	values := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.Value
+	}
+	return values
+}
+
+func evalSegments(root interface{}, nodes []JsonPathMatch, segments []jpSegment) []JsonPathMatch {
+	for _, seg := range segments {
+		next := make([]JsonPathMatch, 0, len(nodes))
+		for _, n := range nodes {
+			if seg.descendant {
+				visitDescendants(n, func(d JsonPathMatch) {
+					next = applySelectors(root, d, seg.selectors, next)
+				})
+			} else {
+				next = applySelectors(root, n, seg.selectors, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// visitDescendants 按文档顺序访问 n 和它的所有后代
+func visitDescendants(n JsonPathMatch, fn func(JsonPathMatch)) {
+	fn(n)
+	if m, ok := asJsonMap(n.Value); ok {
+		for _, key := range sortedKeys(m) {
+			visitDescendants(JsonPathMatch{Path: n.Path + normalizedName(key), Value: m[key]}, fn)
+		}
+	} else if a, ok := asJsonSlice(n.Value); ok {
+		for i, v := range a {
+			visitDescendants(JsonPathMatch{Path: n.Path + "[" + strconv.Itoa(i) + "]", Value: v}, fn)
+		}
+	}
+}
+
+func applySelectors(root interface{}, n JsonPathMatch, selectors []jpSelector, out []JsonPathMatch) []JsonPathMatch {
+	m, isMap := asJsonMap(n.Value)
+	a, isSlice := asJsonSlice(n.Value)
+	child := func(i int) JsonPathMatch {
+		return JsonPathMatch{Path: n.Path + "[" + strconv.Itoa(i) + "]", Value: a[i]}
+	}
+	for _, sel := range selectors {
+		switch sel.kind {
+		case jpName:
+			if v, ok := m[sel.name]; isMap && ok {
+				out = append(out, JsonPathMatch{Path: n.Path + normalizedName(sel.name), Value: v})
+			}
+		case jpWildcard, jpFilter:
+			if isMap {
+				for _, key := range sortedKeys(m) {
+					if sel.kind == jpWildcard || sel.filter.test(root, m[key]) {
+						out = append(out, JsonPathMatch{Path: n.Path + normalizedName(key), Value: m[key]})
+					}
+				}
+			} else if isSlice {
+				for i := range a {
+					if sel.kind == jpWildcard || sel.filter.test(root, a[i]) {
+						out = append(out, child(i))
+					}
+				}
+			}
+		case jpIndex:
+			if i, ok := sliceIndex(sel.index, len(a)); isSlice && ok {
+				out = append(out, child(i))
+			}
+		case jpSlice:
+			if isSlice {
+				for _, i := range sliceIndexes(sel.slice, len(a)) {
+					out = append(out, child(i))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// sliceIndexes 按照 RFC 9535 计算切片选择的下标
+func sliceIndexes(s [3]*int, length int) []int {
+	step := 1
+	if s[2] != nil {
+		step = *s[2]
+	}
+	if step == 0 {
+		return nil
+	}
+	normalize := func(i int) int {
+		if i < 0 {
+			return length + i
+		}
+		return i
+	}
+	clamp := func(i, lo, hi int) int {
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+	var start, end int
+	if step > 0 {
+		start, end = 0, length
+	} else {
+		start, end = length-1, -length-1
+	}
+	if s[0] != nil {
+		start = *s[0]
+	}
+	if s[1] != nil {
+		end = *s[1]
+	}
+	start, end = normalize(start), normalize(end)
+	indexes := make([]int, 0)
+	// 先判断下一个下标是否超出范围再加 step，避免很大的 step 溢出
+	if step > 0 {
+		lo, hi := clamp(start, 0, length), clamp(end, 0, length)
+		for i := lo; i < hi; i += step {
+			indexes = append(indexes, i)
+			if step >= hi-i {
+				break
+			}
+		}
+	} else {
+		lo, hi := clamp(start, -1, length-1), clamp(end, -1, length-1)
+		for i := lo; i > hi; i += step {
+			indexes = append(indexes, i)
+			if step <= hi-i {
+				break
+			}
+		}
+	}
+	return indexes
+}
+
+// normalizedName 返回 RFC 9535 规范化路径中的成员名，比如 ['name']
+func normalizedName(key string) string {
+	var sb strings.Builder
+	sb.WriteString("['")
+	for _, r := range key {
+		switch r {
+		case '\'':
+			sb.WriteString(`\'`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteString("']")
+	return sb.String()
+}
+
+// 过滤表达式
+
+type jpOr struct{ left, right jpExpr }
+type jpAnd struct{ left, right jpExpr }
+type jpNot struct{ expr jpExpr }
+
+// jpExist 存在性测试，比如 ?@.name，查询有结果时为真
+type jpExist struct {
+	absolute bool
+	segments []jpSegment
+}
+
+// jpComparable 比较运算的一边，可以是字面量或者单值查询
+type jpComparable struct {
+	literal   interface{}
+	isLiteral bool
+	query     *jpExist
+}
+
+type jpCompare struct {
+	op          string
+	left, right jpComparable
+}
+
+func (e jpOr) test(root, cur interface{}) bool {
+	return e.left.test(root, cur) || e.right.test(root, cur)
+}
+func (e jpAnd) test(root, cur interface{}) bool {
+	return e.left.test(root, cur) && e.right.test(root, cur)
+}
+func (e jpNot) test(root, cur interface{}) bool {
+	return !e.expr.test(root, cur)
+}
+
+func (e jpExist) eval(root, cur interface{}) []JsonPathMatch {
+	start := JsonPathMatch{Path: "@", Value: cur}
+	if e.absolute {
+		start = JsonPathMatch{Path: "$", Value: root}
+	}
+	return evalSegments(root, []JsonPathMatch{start}, e.segments)
+}
+
+func (e jpExist) test(root, cur interface{}) bool {
+	return len(e.eval(root, cur)) > 0
+}
+
+// value 返回比较运算一边的值，查询结果不是正好一个值时 ok 为 false，表示 RFC 9535 中的 Nothing
+func (c jpComparable) value(root, cur interface{}) (interface{}, bool) {
+	if c.isLiteral {
+		return c.literal, true
+	}
+	nodes := c.query.eval(root, cur)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0].Value, true
+}
+
+func (e jpCompare) test(root, cur interface{}) bool {
+	l, lok := e.left.value(root, cur)
+	r, rok := e.right.value(root, cur)
+	switch e.op {
+	case "==":
+		return jpEqual(l, lok, r, rok)
+	case "!=":
+		return !jpEqual(l, lok, r, rok)
+	case "<":
+		return jpLess(l, lok, r, rok)
+	case ">":
+		return jpLess(r, rok, l, lok)
+	case "<=":
+		return jpLess(l, lok, r, rok) || jpEqual(l, lok, r, rok)
+	case ">=":
+		return jpLess(r, rok, l, lok) || jpEqual(l, lok, r, rok)
+	}
+	return false
+}
+
+func jpEqual(l interface{}, lok bool, r interface{}, rok bool) bool {
+	if !lok || !rok {
+		return !lok && !rok
+	}
+	return jsonEqual(l, r)
+}
+
+func jpLess(l interface{}, lok bool, r interface{}, rok bool) bool {
+	if !lok || !rok {
+		return false
+	}
+	if lr, err := numberRat(l); err == nil {
+		rr, err := numberRat(r)
+		return err == nil && lr.Cmp(rr) < 0
+	}
+	ls, ok1 := l.(string)
+	rs, ok2 := r.(string)
+	return ok1 && ok2 && ls < rs
+}
+
+// 解析器
+
+type jpParser struct {
+	src string
+	pos int
+}
+
+func (p *jpParser) errorf(format string, v ...interface{}) error {
+	return fmt.Errorf("jsonpath %q at %d: %s", p.src, p.pos, fmt.Sprintf(format, v...))
+}
+
+func (p *jpParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *jpParser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *jpParser) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r", p.src[p.pos]) != -1 {
+		p.pos++
+	}
+}
+
+func (p *jpParser) parseSegments() ([]jpSegment, error) {
+	segments := make([]jpSegment, 0)
+	for {
+		save := p.pos
+		p.skipSpace()
+		switch {
+		case p.consume(".."):
+			seg := jpSegment{descendant: true}
+			if p.peek() == '[' {
+				sels, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+			} else {
+				sel, err := p.parseDotSelector()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = []jpSelector{sel}
+			}
+			segments = append(segments, seg)
+		case p.consume("."):
+			sel, err := p.parseDotSelector()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, jpSegment{selectors: []jpSelector{sel}})
+		case p.peek() == '[':
+			sels, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, jpSegment{selectors: sels})
+		default:
+			p.pos = save
+			return segments, nil
+		}
+	}
+}
+
+func isNameFirst(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r >= 0x80
+}
+
+func (p *jpParser) parseDotSelector() (jpSelector, error) {
+	if p.consume("*") {
+		return jpSelector{kind: jpWildcard}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !isNameFirst(r) && !(p.pos > start && r >= '0' && r <= '9') {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return jpSelector{}, p.errorf("expected member name")
+	}
+	return jpSelector{kind: jpName, name: p.src[start:p.pos]}, nil
+}
+
+func (p *jpParser) parseBracket() ([]jpSelector, error) {
+	p.consume("[")
+	sels := make([]jpSelector, 0, 1)
+	for {
+		p.skipSpace()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+		p.skipSpace()
+		if p.consume("]") {
+			return sels, nil
+		}
+		if !p.consume(",") {
+			return nil, p.errorf("expected , or ]")
+		}
+	}
+}
+
+func (p *jpParser) parseSelector() (jpSelector, error) {
+	c := p.peek()
+	switch {
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return jpSelector{kind: jpName, name: s}, err
+	case c == '*':
+		p.pos++
+		return jpSelector{kind: jpWildcard}, nil
+	case c == '?':
+		p.pos++
+		expr, err := p.parseOr()
+		return jpSelector{kind: jpFilter, filter: expr}, err
+	case c == '-' || c == ':' || (c >= '0' && c <= '9'):
+		return p.parseIndexOrSlice()
+	}
+	return jpSelector{}, p.errorf("invalid selector")
+}
+
+func (p *jpParser) parseInt() (*int, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, nil
+	}
+	s := p.src[start:p.pos]
+	if s == "-" || s == "-0" || (len(s) > 1 && s[0] == '0') || (len(s) > 2 && s[:2] == "-0") {
+		return nil, p.errorf("invalid integer %q", s)
+	}
+	// RFC 9535 的整数必须在 I-JSON 的范围内，即 ±(2^53-1)
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n > maxSafeInteger-1 || n < -(maxSafeInteger-1) {
+		return nil, p.errorf("integer %q out of range", s)
+	}
+	i := int(n)
+	return &i, nil
+}
+
+func (p *jpParser) parseIndexOrSlice() (jpSelector, error) {
+	var parts [3]*int
+	for i := 0; i < 3; i++ {
+		p.skipSpace()
+		n, err := p.parseInt()
+		if err != nil {
+			return jpSelector{}, err
+		}
+		parts[i] = n
+		p.skipSpace()
+		if i == 0 && p.peek() != ':' {
+			if n == nil {
+				return jpSelector{}, p.errorf("expected index")
+			}
+			return jpSelector{kind: jpIndex, index: *n}, nil
+		}
+		if i == 2 || !p.consume(":") {
+			break
+		}
+	}
+	return jpSelector{kind: jpSlice, slice: parts}, nil
+}
+
+// parseString 解析单引号或者双引号的字串，转义规则和 json 相同，另外单引号字串中可以使用 \'
+func (p *jpParser) parseString() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			p.pos++
+			e := p.peek()
+			p.pos++
+			switch e {
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '/', '\\', '\'', '"':
+				if (e == '\'' || e == '"') && e != quote {
+					return "", p.errorf("invalid escape \\%c", e)
+				}
+				sb.WriteByte(e)
+			case 'u':
+				r, err := p.parseUnicodeEscape()
+				if err != nil {
+					return "", err
+				}
+				sb.WriteRune(r)
+			default:
+				return "", p.errorf("invalid escape \\%c", e)
+			}
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *jpParser) parseUnicodeEscape() (rune, error) {
+	hex4 := func() (rune, error) {
+		if p.pos+4 > len(p.src) {
+			return 0, p.errorf("invalid \\u escape")
+		}
+		n, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 16)
+		if err != nil {
+			return 0, p.errorf("invalid \\u escape")
+		}
+		p.pos += 4
+		return rune(n), nil
+	}
+	r, err := hex4()
+	if err != nil || !utf16.IsSurrogate(r) {
+		return r, err
+	}
+	if !p.consume(`\u`) {
+		return 0, p.errorf("invalid surrogate pair")
+	}
+	r2, err := hex4()
+	if err != nil {
+		return 0, err
+	}
+	dec := utf16.DecodeRune(r, r2)
+	if dec == utf8.RuneError {
+		return 0, p.errorf("invalid surrogate pair")
+	}
+	return dec, nil
+}
+
+func (p *jpParser) parseOr() (jpExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = jpOr{left, right}
+	}
+}
+
+func (p *jpParser) parseAnd() (jpExpr, error) {
+	left, err := p.parseBasic()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		left = jpAnd{left, right}
+	}
+}
+
+func (p *jpParser) parseBasic() (jpExpr, error) {
+	p.skipSpace()
+	if p.consume("!") {
+		p.skipSpace()
+		if p.peek() == '(' {
+			expr, err := p.parseParen()
+			return jpNot{expr}, err
+		}
+		q, err := p.parseFilterQuery()
+		if err != nil {
+			return nil, err
+		}
+		return jpNot{*q}, nil
+	}
+	if p.peek() == '(' {
+		return p.parseParen()
+	}
+
+	left, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	op := ""
+	for _, o := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		if left.isLiteral {
+			return nil, p.errorf("literal must be compared")
+		}
+		return *left.query, nil
+	}
+	p.skipSpace()
+	right, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	return jpCompare{op: op, left: left, right: right}, nil
+}
+
+func (p *jpParser) parseParen() (jpExpr, error) {
+	p.consume("(")
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if !p.consume(")") {
+		return nil, p.errorf("expected )")
+	}
+	return expr, nil
+}
+
+func (p *jpParser) parseFilterQuery() (*jpExist, error) {
+	var absolute bool
+	if p.consume("$") {
+		absolute = true
+	} else if !p.consume("@") {
+		return nil, p.errorf("expected @ or $")
+	}
+	segments, err := p.parseSegments()
+	if err != nil {
+		return nil, err
+	}
+	return &jpExist{absolute: absolute, segments: segments}, nil
+}
+
+func (p *jpParser) parseComparable() (jpComparable, error) {
+	c := p.peek()
+	switch {
+	case c == '@' || c == '$':
+		q, err := p.parseFilterQuery()
+		return jpComparable{query: q}, err
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return jpComparable{literal: s, isLiteral: true}, err
+	case p.consume("true"):
+		return jpComparable{literal: true, isLiteral: true}, nil
+	case p.consume("false"):
+		return jpComparable{literal: false, isLiteral: true}, nil
+	case p.consume("null"):
+		return jpComparable{literal: nil, isLiteral: true}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) != -1 {
+			p.pos++
+		}
+		num := json.Number(p.src[start:p.pos])
+		if _, err := numberRat(num); err != nil {
+			return jpComparable{}, p.errorf("invalid number %q", string(num))
+		}
+		return jpComparable{literal: num, isLiteral: true}, nil
+	}
+	return jpComparable{}, p.errorf("expected query or literal")
+}
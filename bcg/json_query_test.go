@@ -0,0 +1,46 @@
+package bcg
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJsonPathSliceLargeIntegers(t *testing.T) {
+	root := JsonArray{"a", "b", "c"}
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"$[1::9007199254740991]", `["b"]`},
+		{"$[::-9007199254740991]", `["c"]`},
+		{"$[-9007199254740991:9007199254740991:2]", `["a","c"]`},
+		{"$[9007199254740991:-9007199254740991:-1]", `["c","b","a"]`},
+		{"$[::2]", `["a","c"]`},
+		{"$[::-1]", `["c","b","a"]`},
+		{"$[9007199254740991]", `[]`},
+	}
+	for _, c := range cases {
+		expr, err := CompileJsonPath(c.query)
+		if err != nil {
+			t.Errorf("%s: %v", c.query, err)
+			continue
+		}
+		b, _ := json.Marshal(expr.Values(root))
+		if string(b) != c.want {
+			t.Errorf("%s: got %s, want %s", c.query, b, c.want)
+		}
+	}
+}
+
+func TestJsonPathIntegerRange(t *testing.T) {
+	for _, query := range []string{
+		"$[1::9223372036854775807]",
+		"$[9007199254740992]",
+		"$[-9007199254740992:]",
+		"$[::99999999999999999999]",
+	} {
+		if _, err := CompileJsonPath(query); err == nil {
+			t.Errorf("%s: expected error", query)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package bcg
+
+// json_schema 实现 JSON Schema draft 2020-12 的一个常用子集，用于在加载配置文件时检查配置是否正确。
+// 支持的关键字：type、properties、required、additionalProperties、items、enum、const、
+// minimum、maximum、exclusiveMinimum、exclusiveMaximum、minLength、maxLength、minItems、maxItems、pattern、$ref。
+// $ref 只支持本文档内的引用，比如 "#/$defs/item"。pattern 使用 Go 的 regexp 语法。
+// 校验会报告所有的错误，而不是遇到第一个错误就停止，错误的路径和 GetPath 的路径格式相同。
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// schemaMaxDepth 限制 $ref 的嵌套深度，防止循环引用导致无限递归
+const schemaMaxDepth = 64
+
+type SchemaError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e SchemaError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": " + e.Message
+}
+
+type JsonSchema struct {
+	root     interface{}
+	patterns sync.Map
+}
+
+// NewJsonSchema 使用 JsonObject 形式的 schema 生成校验器
+func NewJsonSchema(schema JsonObject) *JsonSchema {
+	return &JsonSchema{root: map[string]interface{}(schema)}
+}
+
+// ParseJsonSchema 解析 json 字串形式的 schema，数字保存为 json.Number，所以 minimum 等限制是精确的
+func ParseJsonSchema(b []byte) (*JsonSchema, error) {
+	var root interface{}
+	if err := unmarshalUseNumber(b, &root); err != nil {
+		return nil, err
+	}
+	switch root.(type) {
+	case map[string]interface{}, bool:
+	default:
+		return nil, fmt.Errorf("json schema must be an object or a boolean")
+	}
+	return &JsonSchema{root: root}, nil
+}
+
+// LoadJsonSchema 从文件读取 schema
+func LoadJsonSchema(fn string) (*JsonSchema, error) {
+	data := ReadFile(fn)
+	if data == nil {
+		return nil, fmt.Errorf("read json schema %s failed", fn)
+	}
+	return ParseJsonSchema(data)
+}
+
+// Validate 校验 v，v 可以是 JsonObject、JsonArray 或者任何 json 数据模型中的值，返回所有的错误，没有错误时返回空数组
+func (s *JsonSchema) Validate(v interface{}) []SchemaError {
+	errs := make([]SchemaError, 0)
+	s.validate(s.root, v, "", 0, &errs)
+	return errs
+}
+
+// ValidateBytes 解析 json 字串并校验，json 格式错误时返回 error
+func (s *JsonSchema) ValidateBytes(b []byte) ([]SchemaError, error) {
+	var v interface{}
+	if err := unmarshalUseNumber(b, &v); err != nil {
+		return nil, err
+	}
+	return s.Validate(v), nil
+}
+
+// ValidateFile 读取并校验 json 文件
+func (s *JsonSchema) ValidateFile(fn string) ([]SchemaError, error) {
+	data := ReadFile(fn)
+	if data == nil {
+		return nil, fmt.Errorf("read %s failed", fn)
+	}
+	return s.ValidateBytes(data)
+}
+
+func (js *JsonObject) Validate(s *JsonSchema) []SchemaError {
+	return s.Validate(*js)
+}
+
+// JsonLoadConfSchema 和 JsonLoadConf 相同，但是先用 schema 校验配置文件，有任何错误时不会修改 conf，
+// 返回的错误包含所有的校验错误
+func JsonLoadConfSchema(fn string, s *JsonSchema, conf interface{}) error {
+	data := ReadFile(fn)
+	if data == nil {
+		return fmt.Errorf("read %s failed", fn)
+	}
+	errs, err := s.ValidateBytes(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fn, err)
+	}
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Error()
+		}
+		return fmt.Errorf("%s: %s", fn, strings.Join(msgs, "; "))
+	}
+	return json.Unmarshal(data, conf)
+}
+
+func (s *JsonSchema) addError(errs *[]SchemaError, path, format string, v ...interface{}) {
+	*errs = append(*errs, SchemaError{Path: path, Message: fmt.Sprintf(format, v...)})
+}
+
+func (s *JsonSchema) validate(schema, v interface{}, path string, depth int, errs *[]SchemaError) {
+	if b, ok := schema.(bool); ok {
+		if !b {
+			s.addError(errs, path, "value is not allowed")
+		}
+		return
+	}
+	sm, ok := asJsonMap(schema)
+	if !ok {
+		s.addError(errs, path, "invalid schema")
+		return
+	}
+
+	if ref, ok := sm["$ref"].(string); ok {
+		if depth >= schemaMaxDepth {
+			s.addError(errs, path, "$ref %s nested too deep", ref)
+		} else if target, err := s.resolveRef(ref); err != nil {
+			s.addError(errs, path, "bad $ref %s: %v", ref, err)
+		} else {
+			s.validate(target, v, path, depth+1, errs)
+		}
+	}
+	if t, ok := sm["type"]; ok && !s.checkType(t, v) {
+		s.addError(errs, path, "expected type %s, got %s", schemaTypeString(t), JsonTypeName(v))
+		return
+	}
+	if c, ok := sm["const"]; ok && !jsonEqual(c, v) {
+		s.addError(errs, path, "must be %s", diffValue(c))
+	}
+	if enum, ok := asJsonSlice(sm["enum"]); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s.addError(errs, path, "must be one of %s", diffValue(enum))
+		}
+	}
+
+	switch JsonTypeName(v) {
+	case "number":
+		s.validateNumber(sm, v, path, errs)
+	case "string":
+		s.validateString(sm, v.(string), path, errs)
+	case "array":
+		a, _ := asJsonSlice(v)
+		s.validateArray(sm, a, path, depth, errs)
+	case "object":
+		m, _ := asJsonMap(v)
+		s.validateObject(sm, m, path, depth, errs)
+	}
+}
+
+func (s *JsonSchema) resolveRef(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("only local references are supported")
+	}
+	tokens, err := parsePointer(ref[1:])
+	if err != nil {
+		return nil, err
+	}
+	return pointerGet(s.root, tokens)
+}
+
+func schemaTypeString(t interface{}) string {
+	if s, ok := t.(string); ok {
+		return s
+	}
+	return diffValue(t)
+}
+
+func (s *JsonSchema) checkType(t, v interface{}) bool {
+	if types, ok := asJsonSlice(t); ok {
+		for _, item := range types {
+			if s.checkType(item, v) {
+				return true
+			}
+		}
+		return false
+	}
+	name, _ := t.(string)
+	actual := JsonTypeName(v)
+	switch name {
+	case "integer":
+		r, err := numberRat(v)
+		return err == nil && r.IsInt()
+	case "boolean":
+		return actual == "bool"
+	default:
+		return actual == name
+	}
+}
+
+func (s *JsonSchema) validateNumber(sm map[string]interface{}, v interface{}, path string, errs *[]SchemaError) {
+	r, _ := numberRat(v)
+	limit := func(key string, ok func(cmp int) bool, msg string) {
+		lv, exist := sm[key]
+		if !exist {
+			return
+		}
+		lr, err := numberRat(lv)
+		if err != nil {
+			s.addError(errs, path, "invalid %s in schema", key)
+			return
+		}
+		if !ok(r.Cmp(lr)) {
+			s.addError(errs, path, "must be %s %s", msg, decimalString(lr))
+		}
+	}
+	limit("minimum", func(c int) bool { return c >= 0 }, ">=")
+	limit("maximum", func(c int) bool { return c <= 0 }, "<=")
+	limit("exclusiveMinimum", func(c int) bool { return c > 0 }, ">")
+	limit("exclusiveMaximum", func(c int) bool { return c < 0 }, "<")
+}
+
+// schemaInt 读取 minLength 等非负整数关键字
+func schemaInt(sm map[string]interface{}, key string) (int64, bool) {
+	v, ok := sm[key]
+	if !ok {
+		return 0, false
+	}
+	n, err := numberInt64(v)
+	return n, err == nil
+}
+
+func (s *JsonSchema) validateString(sm map[string]interface{}, str, path string, errs *[]SchemaError) {
+	length := int64(utf8.RuneCountInString(str))
+	if n, ok := schemaInt(sm, "minLength"); ok && length < n {
+		s.addError(errs, path, "length must be >= %d", n)
+	}
+	if n, ok := schemaInt(sm, "maxLength"); ok && length > n {
+		s.addError(errs, path, "length must be <= %d", n)
+	}
+	if p, ok := sm["pattern"].(string); ok {
+		re, err := s.pattern(p)
+		if err != nil {
+			s.addError(errs, path, "invalid pattern %s: %v", p, err)
+		} else if !re.MatchString(str) {
+			s.addError(errs, path, "must match pattern %s", p)
+		}
+	}
+}
+
+func (s *JsonSchema) pattern(p string) (*regexp.Regexp, error) {
+	if re, ok := s.patterns.Load(p); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	s.patterns.Store(p, re)
+	return re, nil
+}
+
+func (s *JsonSchema) validateArray(sm map[string]interface{}, a []interface{}, path string, depth int, errs *[]SchemaError) {
+	if n, ok := schemaInt(sm, "minItems"); ok && int64(len(a)) < n {
+		s.addError(errs, path, "must have at least %d items", n)
+	}
+	if n, ok := schemaInt(sm, "maxItems"); ok && int64(len(a)) > n {
+		s.addError(errs, path, "must have at most %d items", n)
+	}
+	if items, ok := sm["items"]; ok {
+		for i, item := range a {
+			s.validate(items, item, fmt.Sprintf("%s[%d]", path, i), depth, errs)
+		}
+	}
+}
+
+func (s *JsonSchema) validateObject(sm map[string]interface{}, m map[string]interface{}, path string, depth int, errs *[]SchemaError) {
+	if required, ok := asJsonSlice(sm["required"]); ok {
+		for _, r := range required {
+			key, _ := r.(string)
+			if _, ok := m[key]; !ok {
+				s.addError(errs, joinPathKey(path, key), "is required")
+			}
+		}
+	}
+	props, _ := asJsonMap(sm["properties"])
+	additional, hasAdditional := sm["additionalProperties"]
+	for _, key := range sortedKeys(m) {
+		p := joinPathKey(path, key)
+		if ps, ok := props[key]; ok {
+			s.validate(ps, m[key], p, depth, errs)
+		} else if hasAdditional {
+			if b, ok := additional.(bool); ok && !b {
+				s.addError(errs, p, "additional property is not allowed")
+			} else {
+				s.validate(additional, m[key], p, depth, errs)
+			}
+		}
+	}
+}
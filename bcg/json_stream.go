@@ -0,0 +1,301 @@
+package bcg
+
+// json_stream 逐个读写大型 json 数组和 JSON Lines 文件中的元素，不需要把整个文档读入内存，适合导出的比赛记录这样的大文件。
+// 读取时每次调用 Next 解析一个元素，到达末尾时返回 io.EOF；解析失败时返回 *JsonStreamError，包含出错元素的序号和字节位置。
+// 写入的数据经过缓冲，结束时必须调用 Close（JsonLinesWriter 也可以调用 Flush）。
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode"
+)
+
+// JsonStreamError 流式读取时的错误，Offset 是出错的字节位置（语法错误时是错误字符的位置，否则是出错元素的起始位置），
+// Line 只有 JSON Lines 才有，从 1 开始
+type JsonStreamError struct {
+	Index  int
+	Offset int64
+	Line   int
+	Err    error
+}
+
+func (e *JsonStreamError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("json stream: element %d at line %d (offset %d): %v", e.Index, e.Line, e.Offset, e.Err)
+	}
+	return fmt.Sprintf("json stream: element %d at offset %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *JsonStreamError) Unwrap() error {
+	return e.Err
+}
+
+// JsonStreamReader JsonArrayReader 和 JsonLinesReader 共同的接口
+type JsonStreamReader interface {
+	Next(v interface{}) error
+	NextJson() (JsonObject, error)
+}
+
+// JsonArrayReader 逐个读取顶层 json 数组中的元素
+type JsonArrayReader struct {
+	dec     *json.Decoder
+	cr      *countingReader
+	index   int
+	started bool
+	done    bool
+}
+
+func NewJsonArrayReader(r io.Reader) *JsonArrayReader {
+	cr := &countingReader{r: r}
+	return &JsonArrayReader{dec: json.NewDecoder(cr), cr: cr}
+}
+
+// countingReader 记录 Decoder 从输入中读取的字节数，用于计算缓冲区在输入中的位置
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// UseNumber 数字解析为 json.Number 而不是 float64，见 ParseStringUseNumber
+func (r *JsonArrayReader) UseNumber() {
+	r.dec.UseNumber()
+}
+
+// Offset 返回已经读取的字节数
+func (r *JsonArrayReader) Offset() int64 {
+	return r.dec.InputOffset()
+}
+
+// Next 把下一个元素解析到 v，v 可以是结构体指针、*JsonObject 等任何 json.Unmarshal 支持的类型，没有更多元素时返回 io.EOF
+func (r *JsonArrayReader) Next(v interface{}) error {
+	if r.done {
+		return io.EOF
+	}
+	if !r.started {
+		offset := r.dec.InputOffset()
+		t, err := r.dec.Token()
+		if err != nil {
+			return &JsonStreamError{Index: 0, Offset: offset, Err: err}
+		}
+		if d, ok := t.(json.Delim); !ok || d != '[' {
+			return &JsonStreamError{Index: 0, Offset: offset, Err: errors.New("top-level value is not an array")}
+		}
+		r.started = true
+	}
+	if !r.dec.More() {
+		offset := r.dec.InputOffset()
+		if _, err := r.dec.Token(); err != nil {
+			return &JsonStreamError{Index: r.index, Offset: offset, Err: err}
+		}
+		r.done = true
+		return io.EOF
+	}
+	offset := r.elementOffset()
+	if err := r.dec.Decode(v); err != nil {
+		// Decoder 的语法错误位置是整个输入中的位置，类型错误的位置是相对于元素起始位置的
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) {
+			return &JsonStreamError{Index: r.index, Offset: offset + te.Offset, Err: err}
+		}
+		return &JsonStreamError{Index: r.index, Offset: errorOffset(err, offset), Err: err}
+	}
+	r.index++
+	return nil
+}
+
+// elementOffset 返回下一个元素的起始位置，需要在 More 之后调用，这时元素和它前面的逗号已经在缓冲区中。
+// 缓冲区的末尾就是已经读取的位置，不依赖 InputOffset 对空白的处理
+func (r *JsonArrayReader) elementOffset() int64 {
+	buf, _ := io.ReadAll(r.dec.Buffered())
+	offset := r.cr.n - int64(len(buf))
+	for _, c := range buf {
+		if c != ',' && c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			break
+		}
+		offset++
+	}
+	return offset
+}
+
+// errorOffset 语法错误和类型错误带有出错的字节位置，比元素的起始位置更准确
+func errorOffset(err error, def int64) int64 {
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		return se.Offset
+	}
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) {
+		return te.Offset
+	}
+	return def
+}
+
+// NextJson 读取下一个对象元素
+func (r *JsonArrayReader) NextJson() (JsonObject, error) {
+	js := NewJsonObject()
+	err := r.Next(&js)
+	return js, err
+}
+
+// JsonLinesReader 逐行读取 JSON Lines 文件，每行一个 json 值，空行会被忽略
+type JsonLinesReader struct {
+	br        *bufio.Reader
+	offset    int64
+	line      int
+	index     int
+	useNumber bool
+}
+
+func NewJsonLinesReader(r io.Reader) *JsonLinesReader {
+	return &JsonLinesReader{br: bufio.NewReaderSize(r, 64*1024)}
+}
+
+func (r *JsonLinesReader) UseNumber() {
+	r.useNumber = true
+}
+func (r *JsonLinesReader) Offset() int64 {
+	return r.offset
+}
+
+func (r *JsonLinesReader) Next(v interface{}) error {
+	for {
+		data, err := r.br.ReadBytes('\n')
+		start := r.offset
+		r.offset += int64(len(data))
+		if len(data) == 0 && err != nil {
+			if err == io.EOF {
+				return io.EOF
+			}
+			return &JsonStreamError{Index: r.index, Offset: start, Line: r.line + 1, Err: err}
+		}
+		r.line++
+		// 错误的位置是相对于去掉空白后的内容的，需要加上行首空白的长度
+		lead := int64(len(data) - len(bytes.TrimLeftFunc(data, unicode.IsSpace)))
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			if err != nil {
+				return io.EOF
+			}
+			continue
+		}
+		if r.useNumber {
+			err = unmarshalUseNumber(data, v)
+		} else {
+			err = json.Unmarshal(data, v)
+		}
+		if err != nil {
+			return &JsonStreamError{Index: r.index, Offset: start + lead + errorOffset(err, 0), Line: r.line, Err: err}
+		}
+		r.index++
+		return nil
+	}
+}
+
+func (r *JsonLinesReader) NextJson() (JsonObject, error) {
+	js := NewJsonObject()
+	err := r.Next(&js)
+	return js, err
+}
+
+// JsonStreamForEach 逐个读取对象元素并调用 cb，cb 返回 false 时停止，正常读取到末尾时返回 nil
+func JsonStreamForEach(r JsonStreamReader, cb func(i int, js JsonObject) bool) error {
+	for i := 0; ; i++ {
+		js, err := r.NextJson()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !cb(i, js) {
+			return nil
+		}
+	}
+}
+
+// JsonArrayWriter 逐个写入元素，生成一个 json 数组
+type JsonArrayWriter struct {
+	bw    *bufio.Writer
+	count int
+}
+
+func NewJsonArrayWriter(w io.Writer) *JsonArrayWriter {
+	return &JsonArrayWriter{bw: bufio.NewWriter(w)}
+}
+
+func (w *JsonArrayWriter) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sep := byte(',')
+	if w.count == 0 {
+		sep = '['
+	}
+	if err = w.bw.WriteByte(sep); err != nil {
+		return err
+	}
+	w.count++
+	_, err = w.bw.Write(data)
+	return err
+}
+
+// Count 返回已经写入的元素数量
+func (w *JsonArrayWriter) Count() int {
+	return w.count
+}
+
+// Close 写入数组的结束符并输出缓冲的数据，不会关闭底层的 io.Writer
+func (w *JsonArrayWriter) Close() error {
+	end := "]"
+	if w.count == 0 {
+		end = "[]"
+	}
+	if _, err := w.bw.WriteString(end); err != nil {
+		return err
+	}
+	return w.bw.Flush()
+}
+
+// JsonLinesWriter 每个元素写入一行
+type JsonLinesWriter struct {
+	bw    *bufio.Writer
+	count int
+}
+
+func NewJsonLinesWriter(w io.Writer) *JsonLinesWriter {
+	return &JsonLinesWriter{bw: bufio.NewWriter(w)}
+}
+
+func (w *JsonLinesWriter) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err = w.bw.Write(data); err != nil {
+		return err
+	}
+	w.count++
+	return w.bw.WriteByte('\n')
+}
+func (w *JsonLinesWriter) Count() int {
+	return w.count
+}
+func (w *JsonLinesWriter) Flush() error {
+	return w.bw.Flush()
+}
+
+// Close 输出缓冲的数据，不会关闭底层的 io.Writer
+func (w *JsonLinesWriter) Close() error {
+	return w.bw.Flush()
+}
@@ -0,0 +1,66 @@
+package bcg
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestJsonArrayReaderErrorOffset(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		index int
+		// want 出错位置之前的内容，错误位置是它的长度
+		want string
+	}{
+		{"type error", `[{"A":1}, {"A":2},   {"A": "str"}]`, 2, `[{"A":1}, {"A":2},   {"A": "str"`},
+		{"type error first", `[ {"A": "x"}]`, 0, `[ {"A": "x"`},
+		{"spaces before comma", "[{}   ,\n\t {\"A\": \"x\"}]", 1, "[{}   ,\n\t {\"A\": \"x\""},
+		{"syntax error", `[{"A":1}, {"A": x}]`, 1, `[{"A":1}, {"A": x`},
+	}
+	for _, c := range cases {
+		testJsonArrayReaderOffset(t, c.name, NewJsonArrayReader(strings.NewReader(c.input)), c.index, c.want)
+		// 每次只读一个字节，元素前面的逗号和空白分布在不同的读取中
+		testJsonArrayReaderOffset(t, c.name+" one byte", NewJsonArrayReader(iotest.OneByteReader(strings.NewReader(c.input))), c.index, c.want)
+	}
+}
+
+func testJsonArrayReaderOffset(t *testing.T, name string, r *JsonArrayReader, index int, want string) {
+	t.Helper()
+	var err error
+	for err == nil {
+		var v struct{ A int }
+		err = r.Next(&v)
+	}
+	var se *JsonStreamError
+	if !errors.As(err, &se) {
+		t.Errorf("%s: expected JsonStreamError, got %v", name, err)
+		return
+	}
+	if se.Index != index || se.Offset != int64(len(want)) {
+		t.Errorf("%s: index %d offset %d, want index %d offset %d", name, se.Index, se.Offset, index, len(want))
+	}
+}
+
+func TestJsonLinesReaderErrorOffset(t *testing.T) {
+	input := "{\"A\":1}\n\n    {\"A\": \"str\"}\n"
+	r := NewJsonLinesReader(strings.NewReader(input))
+	var v struct{ A int }
+	if err := r.Next(&v); err != nil {
+		t.Fatal(err)
+	}
+	err := r.Next(&v)
+	var se *JsonStreamError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected JsonStreamError, got %v", err)
+	}
+	if want := int64(strings.Index(input, `"str"`) + len(`"str"`)); se.Offset != want || se.Line != 3 {
+		t.Errorf("offset %d line %d, want offset %d line 3", se.Offset, se.Line, want)
+	}
+	if err = r.Next(&v); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
@@ -346,6 +346,7 @@ func logFColor(str, trace string, color int) {
 	if filterLog(str) {
 		return
 	}
+	countLog(trace, color)
 	if logParam.LogDb != nil && logParam.SaveToLog {
 		//只显示文件名
 		pos := strings.LastIndex(trace, "/")
@@ -367,6 +368,7 @@ func logColor(str, trace string, color int) {
 	if filterLog(str) {
 		return
 	}
+	countLog(trace, color)
 	if logParam.LogDb != nil && logParam.SaveToLog {
 		//只显示文件名
 		pos := strings.LastIndex(trace, "/")
@@ -388,6 +390,7 @@ func logColorTo(tabId, str, trace string, color int) {
 	if filterLog(str) {
 		return
 	}
+	countLog(trace, color)
 	if logParam.LogDb != nil && logParam.SaveToLog {
 		//只显示文件名
 		pos := strings.LastIndex(trace, "/")
@@ -0,0 +1,290 @@
+package bcg
+
+// log_metrics 统计输出的日志数量，按颜色（级别）和源文件计数，用于在不查询日志表的情况下监控错误数量。
+// GetLogMetrics 返回当前计数的快照，LogMetricsHandler 以 Prometheus 文本格式输出，
+// StartLogRollup 可以把每小时的统计结果保存到数据库。
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const formatHour = "2006-01-02 15:00:00"
+
+// LogMetrics 日志计数的快照，ByColor 和 ByLevel 的 key 分别是颜色名和级别名，ByFile 的 key 是源文件名
+type LogMetrics struct {
+	Total   int64            `json:"total"`
+	ByColor map[string]int64 `json:"by_color"`
+	ByLevel map[string]int64 `json:"by_level"`
+	ByFile  map[string]int64 `json:"by_file"`
+}
+
+type logRollupKey struct {
+	hour  string
+	color int
+	file  string
+}
+
+type logCounter struct {
+	sync.Mutex
+	total   int64
+	byColor map[int]int64
+	byFile  map[string]int64
+	rollup  map[logRollupKey]int64
+	table   string
+	stop    chan struct{}
+}
+
+var logMetrics = logCounter{
+	byColor: map[int]int64{},
+	byFile:  map[string]int64{},
+}
+
+// LogColorName 返回颜色常量对应的名字，比如 TextRed 返回 "red"
+func LogColorName(color int) string {
+	switch color {
+	case TextBlack:
+		return "black"
+	case TextRed:
+		return "red"
+	case TextGreen:
+		return "green"
+	case TextYellow:
+		return "yellow"
+	case TextBlue:
+		return "blue"
+	case TextMagenta:
+		return "magenta"
+	case TextCyan:
+		return "cyan"
+	case TextWhite:
+		return "white"
+	default:
+		return "none"
+	}
+}
+
+// LogLevelName 返回颜色对应的日志级别，Red 是 error，Yellow 是 warn，Green 是 info，其它颜色都是 debug
+func LogLevelName(color int) string {
+	switch color {
+	case TextRed:
+		return "error"
+	case TextYellow:
+		return "warn"
+	case TextGreen:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// traceFile 从 trace 字串中取出源文件名，去掉路径和行号
+func traceFile(trace string) string {
+	pos := strings.LastIndex(trace, "/")
+	if pos != -1 {
+		trace = trace[pos+1:]
+	}
+	pos = strings.LastIndex(trace, ":")
+	if pos != -1 {
+		trace = trace[:pos]
+	}
+	return trace
+}
+
+func countLog(trace string, color int) {
+	file := traceFile(trace)
+	logMetrics.Lock()
+	logMetrics.total++
+	logMetrics.byColor[color]++
+	logMetrics.byFile[file]++
+	if logMetrics.rollup != nil {
+		key := logRollupKey{hour: time.Now().Format(formatHour), color: color, file: file}
+		logMetrics.rollup[key]++
+	}
+	logMetrics.Unlock()
+}
+
+// GetLogMetrics 返回程序启动（或者 ResetLogMetrics）以来的日志计数
+func GetLogMetrics() LogMetrics {
+	m := LogMetrics{
+		ByColor: map[string]int64{},
+		ByLevel: map[string]int64{},
+		ByFile:  map[string]int64{},
+	}
+	logMetrics.Lock()
+	defer logMetrics.Unlock()
+	m.Total = logMetrics.total
+	for color, n := range logMetrics.byColor {
+		m.ByColor[LogColorName(color)] += n
+		m.ByLevel[LogLevelName(color)] += n
+	}
+	for file, n := range logMetrics.byFile {
+		m.ByFile[file] = n
+	}
+	return m
+}
+
+// ResetLogMetrics 清空内存中的计数，尚未保存的每小时统计不受影响
+func ResetLogMetrics() {
+	logMetrics.Lock()
+	logMetrics.total = 0
+	logMetrics.byColor = map[int]int64{}
+	logMetrics.byFile = map[string]int64{}
+	logMetrics.Unlock()
+}
+
+// LogMetricsHandler 以 Prometheus 文本格式输出日志计数，可以直接挂在 http.ServeMux 上，比如
+// http.Handle("/metrics", bcg.LogMetricsHandler())
+func LogMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write([]byte(logMetricsText()))
+	})
+}
+
+func logMetricsText() string {
+	logMetrics.Lock()
+	colors := make([]int, 0, len(logMetrics.byColor))
+	byColor := make(map[int]int64, len(logMetrics.byColor))
+	for color, n := range logMetrics.byColor {
+		colors = append(colors, color)
+		byColor[color] = n
+	}
+	files := make([]string, 0, len(logMetrics.byFile))
+	byFile := make(map[string]int64, len(logMetrics.byFile))
+	for file, n := range logMetrics.byFile {
+		files = append(files, file)
+		byFile[file] = n
+	}
+	logMetrics.Unlock()
+	sort.Ints(colors)
+	sort.Strings(files)
+
+	var sb strings.Builder
+	sb.WriteString("# HELP bcg_log_entries_total Number of log entries emitted by color and level.\n")
+	sb.WriteString("# TYPE bcg_log_entries_total counter\n")
+	for _, color := range colors {
+		fmt.Fprintf(&sb, "bcg_log_entries_total{color=%q,level=%q} %d\n",
+			LogColorName(color), LogLevelName(color), byColor[color])
+	}
+	sb.WriteString("# HELP bcg_log_file_entries_total Number of log entries emitted by source file.\n")
+	sb.WriteString("# TYPE bcg_log_file_entries_total counter\n")
+	for _, file := range files {
+		fmt.Fprintf(&sb, "bcg_log_file_entries_total{file=%q} %d\n", file, byFile[file])
+	}
+	return sb.String()
+}
+
+// StartLogRollup 开始把每小时的日志计数保存到 LogDb 的 table 表，每一行是一个小时内某个颜色、某个源文件的日志数量。
+// 已经结束的小时每分钟保存一次，StopLogRollup 会保存所有剩余的数据。必须先调用 SetLogParam 设置数据库。
+func StartLogRollup(table string) bool {
+	if logParam.LogDb == nil {
+		outputLogTrace(TextRed, 1, "log database not set")
+		return false
+	}
+	if table == "" {
+		table = logParam.LogTable + "_rollup"
+	}
+	if !createLogRollupTable(table) {
+		return false
+	}
+	logMetrics.Lock()
+	if logMetrics.stop != nil {
+		logMetrics.Unlock()
+		return true
+	}
+	logMetrics.table = table
+	logMetrics.rollup = map[logRollupKey]int64{}
+	logMetrics.stop = make(chan struct{})
+	stop := logMetrics.stop
+	logMetrics.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				flushLogRollup(false)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return true
+}
+
+// StopLogRollup 停止保存每小时的日志计数，并把尚未保存的数据（包括当前小时）写入数据库
+func StopLogRollup() {
+	logMetrics.Lock()
+	if logMetrics.stop == nil {
+		logMetrics.Unlock()
+		return
+	}
+	close(logMetrics.stop)
+	logMetrics.stop = nil
+	logMetrics.Unlock()
+	flushLogRollup(true)
+	logMetrics.Lock()
+	logMetrics.rollup = nil
+	logMetrics.Unlock()
+}
+
+func createLogRollupTable(table string) bool {
+	var createCase string
+	if logParam.DbType == DbTypeSqlite {
+		createCase = `CREATE TABLE IF NOT EXISTS ` + table + `(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		hour VARCHAR(32) NOT NULL,
+		color int,
+		file VARCHAR(255) NOT NULL,
+		count INTEGER NOT NULL
+	);`
+	} else {
+		createCase = `CREATE TABLE IF NOT EXISTS ` + table + `(
+		id INTEGER PRIMARY KEY AUTO_INCREMENT,
+		hour VARCHAR(32) NOT NULL,
+		color int,
+		file VARCHAR(255) NOT NULL,
+		count INTEGER NOT NULL
+	);`
+	}
+	_, err := logParam.LogDb.Exec(createCase)
+	return !checkLogError(err)
+}
+
+// flushLogRollup 保存已经结束的小时的计数，all 为 true 时保存全部
+func flushLogRollup(all bool) {
+	now := time.Now().Format(formatHour)
+	logMetrics.Lock()
+	table := logMetrics.table
+	done := map[logRollupKey]int64{}
+	for key, n := range logMetrics.rollup {
+		if all || key.hour != now {
+			done[key] = n
+			delete(logMetrics.rollup, key)
+		}
+	}
+	logMetrics.Unlock()
+	if len(done) == 0 || logParam.LogDb == nil {
+		return
+	}
+
+	tx, err := logParam.LogDb.Begin()
+	if checkLogError(err) {
+		return
+	}
+	query := "INSERT INTO " + table + " (hour,color,file,count) VALUES (?,?,?,?)"
+	for key, n := range done {
+		_, err = tx.Exec(query, key.hour, key.color, key.file, n)
+		if checkLogError(err) {
+			_ = tx.Rollback()
+			return
+		}
+	}
+	checkLogError(tx.Commit())
+}
@@ -1,12 +1,12 @@
 package bcg
 
 import (
+	"context"
 	"database/sql"
 	"os"
+	"time"
 )
 
-var db *sql.DB
-
 func OpenSqlite(path, dbname string) (*sql.DB, error) {
 	if path == "" {
 		path = "./data"
@@ -19,13 +19,20 @@ func OpenSqlite(path, dbname string) (*sql.DB, error) {
 }
 
 func OpenMysql(user, pass, host, dbname string) {
-	var err error
-	db, err = sql.Open("mysql", user+":"+pass+"@tcp("+host+")/"+dbname+"?charset=utf8")
-	CheckError(err)
+	h, err := sql.Open("mysql", mysqlDsn(user, pass, host, dbname))
+	if CheckError(err) {
+		return
+	}
+	RegisterDb(DefaultDbName, h)
 }
 
+func mysqlDsn(user, pass, host, dbname string) string {
+	return user + ":" + pass + "@tcp(" + host + ")/" + dbname + "?charset=utf8"
+}
+
+// GetDb 返回默认连接，其它连接使用 Use(name).DB()
 func GetDb() *sql.DB {
-	return db
+	return Use(DefaultDbName).DB()
 }
 
 type QueryCall func(rows *sql.Rows)
@@ -34,15 +41,23 @@ type QueryFunc func(string, QueryCall, ...interface{}) error
 // Query 查询需要返回数据的语句，数据从回调函数的 rows 里获取，无需执行 rows 的 Close 函数，
 // 这个设计的目的是减少遗忘 Close 的可能，因为遗忘 Close 不会对程序有立即的影响，直到 Mysql
 // 资源被耗尽，对于海量的查询语句来说，定位哪里忘记 Close 是非常困难的。
+// 多个数据库连接时使用 Use(name).Query，这个函数使用默认连接。
 func Query(sqlCase string, qc QueryCall, v ...interface{}) error {
-	rows, err := db.Query(sqlCase, v...)
-	if CheckErrTrace(err, 2) {
-		return err
-	}
-	qc(rows)
-	_ = rows.Close()
-	return nil
+	return Use(DefaultDbName).query(context.Background(), 3, sqlCase, qc, v...)
+}
+
+// QueryContext ctx 被取消或者超时后回调函数中的 rows.Next 返回 false，长时间的读取会提前结束，函数返回 ctx 的错误
+func QueryContext(ctx context.Context, sqlCase string, qc QueryCall, v ...interface{}) error {
+	return Use(DefaultDbName).query(ctx, 3, sqlCase, qc, v...)
 }
 func Exec(sqlCase string, v ...interface{}) (sql.Result, error) {
-	return db.Exec(sqlCase, v...)
+	return Use(DefaultDbName).Exec(sqlCase, v...)
+}
+func ExecContext(ctx context.Context, sqlCase string, v ...interface{}) (sql.Result, error) {
+	return Use(DefaultDbName).ExecContext(ctx, sqlCase, v...)
+}
+
+// SetDbTimeout 设置默认连接的超时时间，见 SqlDb.SetTimeout
+func SetDbTimeout(timeout time.Duration) {
+	Use(DefaultDbName).SetTimeout(timeout)
 }
@@ -0,0 +1,848 @@
+package bcg
+
+// sql_builder 生成 SELECT、INSERT、UPDATE、DELETE 语句，所有的值都使用占位符，表名和列名会被检查并加上引号，
+// 避免拼接字串导致的 SQL 注入。生成的语句和参数可以直接传给 Query、Exec 等函数：
+//
+//	b := bcg.NewSqlBuilder(bcg.DialectMysql)
+//	sqlCase, args, err := b.Select("u.id", "u.name", "o.gold").From("user u").
+//		LeftJoin("bag o", bcg.SqlColEq("o.uid", "u.id")).
+//		Where(bcg.SqlGe("u.level", 10), bcg.SqlIn("u.state", 1, 2)).
+//		OrderByDesc("u.id").Page(2, 20).Build()
+//
+// 不同数据库的差别：占位符 Mysql 和 Sqlite 使用 ?，PostgreSQL 使用 $1、$2；标识符 Mysql 使用反引号，其它使用双引号；
+// 只有 OFFSET 没有 LIMIT 时 Mysql 和 Sqlite 需要一个最大的 LIMIT；upsert 在 Mysql 中使用 ON DUPLICATE KEY UPDATE，
+// 其它使用 ON CONFLICT。
+// 表名和列名只能包含字母、数字和下划线，可以用 . 分隔表名和列名，列名可以是 * 或者 t.*，表名后面可以有一个别名。
+// SqlExpr 和 ColumnExpr 的内容不会被检查，不能包含用户输入的数据。
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SqlDialect 生成语句的数据库类型
+type SqlDialect int
+
+const (
+	DialectMysql SqlDialect = iota
+	DialectSqlite
+	DialectPostgres
+)
+
+var sqlIdentRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqlWriter 生成语句时记录参数，第一个错误会被保存，之后的错误被忽略
+type sqlWriter struct {
+	dialect SqlDialect
+	sb      strings.Builder
+	args    []interface{}
+	err     error
+}
+
+func (w *sqlWriter) write(s string) {
+	w.sb.WriteString(s)
+}
+
+func (w *sqlWriter) fail(format string, v ...interface{}) {
+	if w.err == nil {
+		w.err = fmt.Errorf("sql builder: "+format, v...)
+	}
+}
+
+func (w *sqlWriter) placeholder() string {
+	if w.dialect == DialectPostgres {
+		return "$" + strconv.Itoa(len(w.args))
+	}
+	return "?"
+}
+
+func (w *sqlWriter) arg(v interface{}) {
+	w.args = append(w.args, v)
+	w.write(w.placeholder())
+}
+
+func (w *sqlWriter) quote(name string) string {
+	if w.dialect == DialectMysql {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
+// ident 检查并输出标识符，allowStar 表示可以是 * 或者 t.*
+func (w *sqlWriter) ident(name string, allowStar bool) {
+	parts := strings.Split(name, ".")
+	if len(parts) > 3 {
+		w.fail("invalid identifier %q", name)
+		return
+	}
+	for i, p := range parts {
+		if i > 0 {
+			w.write(".")
+		}
+		if p == "*" && allowStar && i == len(parts)-1 {
+			w.write("*")
+			continue
+		}
+		if !sqlIdentRegexp.MatchString(p) {
+			w.fail("invalid identifier %q", name)
+			return
+		}
+		w.write(w.quote(p))
+	}
+}
+
+// table 输出表名和可选的别名，比如 "user" 或者 "user u"
+func (w *sqlWriter) table(name string) {
+	fields := strings.Fields(name)
+	if len(fields) == 3 && strings.EqualFold(fields[1], "AS") {
+		fields = []string{fields[0], fields[2]}
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		w.fail("invalid table %q", name)
+		return
+	}
+	w.ident(fields[0], false)
+	if len(fields) == 2 {
+		w.write(" ")
+		w.ident(fields[1], false)
+	}
+}
+
+func (w *sqlWriter) identList(names []string, allowStar bool) {
+	for i, name := range names {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.ident(name, allowStar)
+	}
+}
+
+// expr 输出原始的表达式，表达式中引号以外的 ? 被替换为占位符
+func (w *sqlWriter) expr(sqlCase string, args []interface{}) {
+	var quote rune
+	n := 0
+	for _, c := range sqlCase {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '?':
+			if n >= len(args) {
+				w.fail("not enough arguments for %q", sqlCase)
+				return
+			}
+			w.arg(args[n])
+			n++
+			continue
+		}
+		w.sb.WriteRune(c)
+	}
+	if n != len(args) {
+		w.fail("too many arguments for %q", sqlCase)
+	}
+}
+
+// cond 输出条件，条件为 nil 时返回错误
+func (w *sqlWriter) cond(c SqlCond) {
+	if c == nil {
+		w.fail("nil condition")
+		return
+	}
+	c.writeSql(w)
+}
+
+func (w *sqlWriter) result() (string, []interface{}, error) {
+	if w.err != nil {
+		return "", nil, w.err
+	}
+	return w.sb.String(), w.args, nil
+}
+
+// SqlCond WHERE、HAVING 和 JOIN 的条件
+type SqlCond interface {
+	writeSql(w *sqlWriter)
+}
+
+type sqlOpCond struct {
+	col string
+	op  string
+	v   interface{}
+}
+
+func (c sqlOpCond) writeSql(w *sqlWriter) {
+	w.ident(c.col, false)
+	w.write(" " + c.op + " ")
+	w.arg(c.v)
+}
+
+// SqlEq col = v，v 为 nil 时生成 col IS NULL
+func SqlEq(col string, v interface{}) SqlCond {
+	if v == nil {
+		return SqlIsNull(col)
+	}
+	return sqlOpCond{col, "=", v}
+}
+
+// SqlNe col <> v，v 为 nil 时生成 col IS NOT NULL
+func SqlNe(col string, v interface{}) SqlCond {
+	if v == nil {
+		return SqlNotNull(col)
+	}
+	return sqlOpCond{col, "<>", v}
+}
+func SqlGt(col string, v interface{}) SqlCond {
+	return sqlOpCond{col, ">", v}
+}
+func SqlGe(col string, v interface{}) SqlCond {
+	return sqlOpCond{col, ">=", v}
+}
+func SqlLt(col string, v interface{}) SqlCond {
+	return sqlOpCond{col, "<", v}
+}
+func SqlLe(col string, v interface{}) SqlCond {
+	return sqlOpCond{col, "<=", v}
+}
+func SqlLike(col string, pattern string) SqlCond {
+	return sqlOpCond{col, "LIKE", pattern}
+}
+
+type sqlNullCond struct {
+	col string
+	not bool
+}
+
+func (c sqlNullCond) writeSql(w *sqlWriter) {
+	w.ident(c.col, false)
+	if c.not {
+		w.write(" IS NOT NULL")
+	} else {
+		w.write(" IS NULL")
+	}
+}
+
+func SqlIsNull(col string) SqlCond {
+	return sqlNullCond{col: col}
+}
+func SqlNotNull(col string) SqlCond {
+	return sqlNullCond{col: col, not: true}
+}
+
+type sqlInCond struct {
+	col  string
+	not  bool
+	vals []interface{}
+}
+
+func (c sqlInCond) writeSql(w *sqlWriter) {
+	if len(c.vals) == 0 {
+		// 空的 IN 列表在 SQL 中是语法错误，IN () 永远为假，NOT IN () 永远为真
+		if c.not {
+			w.write("1=1")
+		} else {
+			w.write("1=0")
+		}
+		return
+	}
+	w.ident(c.col, false)
+	if c.not {
+		w.write(" NOT IN (")
+	} else {
+		w.write(" IN (")
+	}
+	for i, v := range c.vals {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.arg(v)
+	}
+	w.write(")")
+}
+
+// SqlIn col IN (vals...)，vals 为空时条件永远为假
+func SqlIn(col string, vals ...interface{}) SqlCond {
+	return sqlInCond{col: col, vals: vals}
+}
+
+// SqlNotIn col NOT IN (vals...)，vals 为空时条件永远为真
+func SqlNotIn(col string, vals ...interface{}) SqlCond {
+	return sqlInCond{col: col, not: true, vals: vals}
+}
+
+type sqlBetweenCond struct {
+	col      string
+	min, max interface{}
+}
+
+func (c sqlBetweenCond) writeSql(w *sqlWriter) {
+	w.ident(c.col, false)
+	w.write(" BETWEEN ")
+	w.arg(c.min)
+	w.write(" AND ")
+	w.arg(c.max)
+}
+
+func SqlBetween(col string, min, max interface{}) SqlCond {
+	return sqlBetweenCond{col, min, max}
+}
+
+type sqlColCond struct {
+	left, op, right string
+}
+
+func (c sqlColCond) writeSql(w *sqlWriter) {
+	w.ident(c.left, false)
+	w.write(" " + c.op + " ")
+	w.ident(c.right, false)
+}
+
+// SqlColEq 比较两个列，用于 JOIN 的条件
+func SqlColEq(left, right string) SqlCond {
+	return sqlColCond{left, "=", right}
+}
+
+type sqlListCond struct {
+	op    string
+	conds []SqlCond
+}
+
+func (c sqlListCond) writeSql(w *sqlWriter) {
+	if len(c.conds) == 0 {
+		// 没有条件的 AND 为真，OR 为假
+		if c.op == "AND" {
+			w.write("1=1")
+		} else {
+			w.write("1=0")
+		}
+		return
+	}
+	if len(c.conds) == 1 {
+		w.cond(c.conds[0])
+		return
+	}
+	w.write("(")
+	for i, cond := range c.conds {
+		if i > 0 {
+			w.write(" " + c.op + " ")
+		}
+		w.cond(cond)
+	}
+	w.write(")")
+}
+
+func SqlAnd(conds ...SqlCond) SqlCond {
+	return sqlListCond{"AND", conds}
+}
+func SqlOr(conds ...SqlCond) SqlCond {
+	return sqlListCond{"OR", conds}
+}
+
+type sqlNotCond struct {
+	cond SqlCond
+}
+
+func (c sqlNotCond) writeSql(w *sqlWriter) {
+	w.write("NOT (")
+	w.cond(c.cond)
+	w.write(")")
+}
+
+func SqlNot(cond SqlCond) SqlCond {
+	return sqlNotCond{cond}
+}
+
+type sqlExprCond struct {
+	sql  string
+	args []interface{}
+}
+
+func (c sqlExprCond) writeSql(w *sqlWriter) {
+	w.write("(")
+	w.expr(c.sql, c.args)
+	w.write(")")
+}
+
+// SqlExpr 原始的条件表达式，用 ? 表示参数，会被替换为对应数据库的占位符
+func SqlExpr(sqlCase string, args ...interface{}) SqlCond {
+	return sqlExprCond{sqlCase, args}
+}
+
+func writeWhere(w *sqlWriter, keyword string, conds []SqlCond) {
+	if len(conds) == 0 {
+		return
+	}
+	w.write(" " + keyword + " ")
+	for i, cond := range conds {
+		if i > 0 {
+			w.write(" AND ")
+		}
+		w.cond(cond)
+	}
+}
+
+// SqlBuilder 生成指定数据库的语句
+type SqlBuilder struct {
+	dialect SqlDialect
+}
+
+func NewSqlBuilder(dialect SqlDialect) SqlBuilder {
+	return SqlBuilder{dialect: dialect}
+}
+
+type sqlJoin struct {
+	kind  string
+	table string
+	on    SqlCond
+}
+
+type sqlOrder struct {
+	col  string
+	desc bool
+}
+
+type SelectBuilder struct {
+	dialect  SqlDialect
+	distinct bool
+	cols     []string
+	exprs    []string
+	table    string
+	joins    []sqlJoin
+	where    []SqlCond
+	groupBy  []string
+	having   []SqlCond
+	orders   []sqlOrder
+	limit    int64
+	offset   int64
+}
+
+// Select 没有列时查询 *
+func (b SqlBuilder) Select(cols ...string) *SelectBuilder {
+	return &SelectBuilder{dialect: b.dialect, cols: cols}
+}
+
+func (s *SelectBuilder) Distinct() *SelectBuilder {
+	s.distinct = true
+	return s
+}
+
+// ColumnExpr 添加一个不检查的列表达式，比如 "COUNT(*) AS n"
+func (s *SelectBuilder) ColumnExpr(expr string) *SelectBuilder {
+	s.exprs = append(s.exprs, expr)
+	return s
+}
+
+// From 表名后面可以有别名，比如 "user u"
+func (s *SelectBuilder) From(table string) *SelectBuilder {
+	s.table = table
+	return s
+}
+
+// Join on 为 nil 时生成 CROSS JOIN
+func (s *SelectBuilder) Join(table string, on SqlCond) *SelectBuilder {
+	kind := "JOIN"
+	if on == nil {
+		kind = "CROSS JOIN"
+	}
+	s.joins = append(s.joins, sqlJoin{kind, table, on})
+	return s
+}
+
+// LeftJoin on 不能为 nil
+func (s *SelectBuilder) LeftJoin(table string, on SqlCond) *SelectBuilder {
+	s.joins = append(s.joins, sqlJoin{"LEFT JOIN", table, on})
+	return s
+}
+
+// Where 多次调用时所有的条件使用 AND 连接
+func (s *SelectBuilder) Where(conds ...SqlCond) *SelectBuilder {
+	s.where = append(s.where, conds...)
+	return s
+}
+
+func (s *SelectBuilder) GroupBy(cols ...string) *SelectBuilder {
+	s.groupBy = append(s.groupBy, cols...)
+	return s
+}
+
+func (s *SelectBuilder) Having(conds ...SqlCond) *SelectBuilder {
+	s.having = append(s.having, conds...)
+	return s
+}
+
+func (s *SelectBuilder) OrderBy(cols ...string) *SelectBuilder {
+	for _, col := range cols {
+		s.orders = append(s.orders, sqlOrder{col: col})
+	}
+	return s
+}
+
+func (s *SelectBuilder) OrderByDesc(cols ...string) *SelectBuilder {
+	for _, col := range cols {
+		s.orders = append(s.orders, sqlOrder{col: col, desc: true})
+	}
+	return s
+}
+
+// Limit 0 表示不限制
+func (s *SelectBuilder) Limit(n int64) *SelectBuilder {
+	s.limit = n
+	return s
+}
+
+func (s *SelectBuilder) Offset(n int64) *SelectBuilder {
+	s.offset = n
+	return s
+}
+
+// Page 分页查询，page 从 1 开始
+func (s *SelectBuilder) Page(page, size int64) *SelectBuilder {
+	if page < 1 {
+		page = 1
+	}
+	s.limit = size
+	s.offset = (page - 1) * size
+	return s
+}
+
+func (s *SelectBuilder) Build() (string, []interface{}, error) {
+	w := &sqlWriter{dialect: s.dialect}
+	w.write("SELECT ")
+	if s.distinct {
+		w.write("DISTINCT ")
+	}
+	if len(s.cols) == 0 && len(s.exprs) == 0 {
+		w.write("*")
+	}
+	w.identList(s.cols, true)
+	for i, expr := range s.exprs {
+		if i > 0 || len(s.cols) > 0 {
+			w.write(", ")
+		}
+		w.write(expr)
+	}
+	if s.table == "" {
+		w.fail("select without table")
+	}
+	w.write(" FROM ")
+	w.table(s.table)
+	for _, j := range s.joins {
+		w.write(" " + j.kind + " ")
+		w.table(j.table)
+		if j.kind == "CROSS JOIN" {
+			continue
+		}
+		w.write(" ON ")
+		w.cond(j.on)
+	}
+	writeWhere(w, "WHERE", s.where)
+	if len(s.groupBy) > 0 {
+		w.write(" GROUP BY ")
+		w.identList(s.groupBy, false)
+	}
+	writeWhere(w, "HAVING", s.having)
+	for i, o := range s.orders {
+		if i == 0 {
+			w.write(" ORDER BY ")
+		} else {
+			w.write(", ")
+		}
+		w.ident(o.col, false)
+		if o.desc {
+			w.write(" DESC")
+		}
+	}
+	s.writeLimit(w)
+	return w.result()
+}
+
+func (s *SelectBuilder) writeLimit(w *sqlWriter) {
+	if s.limit < 0 || s.offset < 0 {
+		w.fail("negative limit or offset")
+		return
+	}
+	if s.limit > 0 {
+		w.write(" LIMIT " + strconv.FormatInt(s.limit, 10))
+	} else if s.offset > 0 {
+		// Mysql 和 Sqlite 的 OFFSET 必须和 LIMIT 一起使用
+		switch s.dialect {
+		case DialectMysql:
+			w.write(" LIMIT 18446744073709551615")
+		case DialectSqlite:
+			w.write(" LIMIT -1")
+		}
+	}
+	if s.offset > 0 {
+		w.write(" OFFSET " + strconv.FormatInt(s.offset, 10))
+	}
+}
+
+type InsertBuilder struct {
+	dialect   SqlDialect
+	table     string
+	cols      []string
+	rows      [][]interface{}
+	conflict  []string
+	update    []string
+	doNothing bool
+	returning []string
+	err       error
+}
+
+func (b SqlBuilder) InsertInto(table string) *InsertBuilder {
+	return &InsertBuilder{dialect: b.dialect, table: table}
+}
+
+func (s *InsertBuilder) Columns(cols ...string) *InsertBuilder {
+	s.cols = cols
+	return s
+}
+
+// Values 添加一行，值的数量必须和列的数量相同，多次调用时插入多行
+func (s *InsertBuilder) Values(vals ...interface{}) *InsertBuilder {
+	s.rows = append(s.rows, vals)
+	return s
+}
+
+// Row 用 map 添加一行，列按名字排序，多次调用时每一行的列必须相同
+func (s *InsertBuilder) Row(m map[string]interface{}) *InsertBuilder {
+	if s.cols == nil {
+		s.cols = sortedKeys(m)
+	}
+	vals := make([]interface{}, 0, len(s.cols))
+	for _, col := range s.cols {
+		v, ok := m[col]
+		if !ok && s.err == nil {
+			s.err = fmt.Errorf("sql builder: row %d has no column %q", len(s.rows), col)
+		}
+		vals = append(vals, v)
+	}
+	if len(m) != len(s.cols) && s.err == nil {
+		s.err = fmt.Errorf("sql builder: row %d has %d columns, expected %d", len(s.rows), len(m), len(s.cols))
+	}
+	s.rows = append(s.rows, vals)
+	return s
+}
+
+// OnConflict 指定 upsert 冲突的唯一键，Sqlite 和 PostgreSQL 使用，Mysql 使用表上所有的唯一键，会忽略这个设置
+func (s *InsertBuilder) OnConflict(keys ...string) *InsertBuilder {
+	s.conflict = keys
+	return s
+}
+
+// DoUpdate 冲突时用插入的值更新 cols
+func (s *InsertBuilder) DoUpdate(cols ...string) *InsertBuilder {
+	s.update = cols
+	return s
+}
+
+// DoNothing 冲突时什么都不做
+func (s *InsertBuilder) DoNothing() *InsertBuilder {
+	s.doNothing = true
+	return s
+}
+
+// Returning 返回插入行的列，Mysql 不支持，Sqlite 需要 3.35 以上的版本
+func (s *InsertBuilder) Returning(cols ...string) *InsertBuilder {
+	s.returning = cols
+	return s
+}
+
+func (s *InsertBuilder) Build() (string, []interface{}, error) {
+	w := &sqlWriter{dialect: s.dialect, err: s.err}
+	if len(s.cols) == 0 || len(s.rows) == 0 {
+		w.fail("insert without columns or values")
+		return w.result()
+	}
+	w.write("INSERT INTO ")
+	w.table(s.table)
+	w.write(" (")
+	w.identList(s.cols, false)
+	w.write(") VALUES ")
+	for i, row := range s.rows {
+		if len(row) != len(s.cols) {
+			w.fail("row %d has %d values, expected %d", i, len(row), len(s.cols))
+			break
+		}
+		if i > 0 {
+			w.write(", ")
+		}
+		w.write("(")
+		for j, v := range row {
+			if j > 0 {
+				w.write(", ")
+			}
+			w.arg(v)
+		}
+		w.write(")")
+	}
+	if w.err != nil {
+		return w.result()
+	}
+	s.writeUpsert(w)
+	if len(s.returning) > 0 {
+		if s.dialect == DialectMysql {
+			w.fail("RETURNING is not supported by mysql")
+		}
+		w.write(" RETURNING ")
+		w.identList(s.returning, true)
+	}
+	return w.result()
+}
+
+func (s *InsertBuilder) writeUpsert(w *sqlWriter) {
+	if len(s.update) == 0 && !s.doNothing {
+		return
+	}
+	if s.dialect == DialectMysql {
+		w.write(" ON DUPLICATE KEY UPDATE ")
+		update := s.update
+		if len(update) == 0 {
+			// Mysql 没有 DO NOTHING，把第一列更新为自己
+			update = s.cols[:1]
+		}
+		for i, col := range update {
+			if i > 0 {
+				w.write(", ")
+			}
+			w.ident(col, false)
+			if len(s.update) == 0 {
+				w.write("=")
+				w.ident(col, false)
+			} else {
+				w.write("=VALUES(")
+				w.ident(col, false)
+				w.write(")")
+			}
+		}
+		return
+	}
+	w.write(" ON CONFLICT")
+	if len(s.conflict) > 0 {
+		w.write(" (")
+		w.identList(s.conflict, false)
+		w.write(")")
+	} else if len(s.update) > 0 {
+		w.fail("upsert with DoUpdate needs OnConflict keys")
+	}
+	if len(s.update) == 0 {
+		w.write(" DO NOTHING")
+		return
+	}
+	w.write(" DO UPDATE SET ")
+	for i, col := range s.update {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.ident(col, false)
+		w.write("=excluded.")
+		w.ident(col, false)
+	}
+}
+
+type sqlSet struct {
+	col  string
+	expr string
+	args []interface{}
+}
+
+type UpdateBuilder struct {
+	dialect  SqlDialect
+	table    string
+	sets     []sqlSet
+	where    []SqlCond
+	allowAll bool
+}
+
+func (b SqlBuilder) Update(table string) *UpdateBuilder {
+	return &UpdateBuilder{dialect: b.dialect, table: table}
+}
+
+func (s *UpdateBuilder) Set(col string, v interface{}) *UpdateBuilder {
+	s.sets = append(s.sets, sqlSet{col: col, expr: "?", args: []interface{}{v}})
+	return s
+}
+
+// SetExpr 用表达式设置列，比如 SetExpr("gold", "gold-?", price)
+func (s *UpdateBuilder) SetExpr(col, expr string, args ...interface{}) *UpdateBuilder {
+	s.sets = append(s.sets, sqlSet{col: col, expr: expr, args: args})
+	return s
+}
+
+// SetMap 按列名排序设置多个列
+func (s *UpdateBuilder) SetMap(m map[string]interface{}) *UpdateBuilder {
+	for _, k := range sortedKeys(m) {
+		s.Set(k, m[k])
+	}
+	return s
+}
+
+func (s *UpdateBuilder) Where(conds ...SqlCond) *UpdateBuilder {
+	s.where = append(s.where, conds...)
+	return s
+}
+
+// AllowAll 允许没有 WHERE 条件，否则 Build 返回错误，防止忘记条件更新整个表
+func (s *UpdateBuilder) AllowAll() *UpdateBuilder {
+	s.allowAll = true
+	return s
+}
+
+func (s *UpdateBuilder) Build() (string, []interface{}, error) {
+	w := &sqlWriter{dialect: s.dialect}
+	if len(s.sets) == 0 {
+		w.fail("update without columns")
+	}
+	if len(s.where) == 0 && !s.allowAll {
+		w.fail("update without where, call AllowAll to update all rows")
+	}
+	w.write("UPDATE ")
+	w.table(s.table)
+	w.write(" SET ")
+	for i, set := range s.sets {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.ident(set.col, false)
+		w.write("=")
+		w.expr(set.expr, set.args)
+	}
+	writeWhere(w, "WHERE", s.where)
+	return w.result()
+}
+
+type DeleteBuilder struct {
+	dialect  SqlDialect
+	table    string
+	where    []SqlCond
+	allowAll bool
+}
+
+func (b SqlBuilder) DeleteFrom(table string) *DeleteBuilder {
+	return &DeleteBuilder{dialect: b.dialect, table: table}
+}
+
+func (s *DeleteBuilder) Where(conds ...SqlCond) *DeleteBuilder {
+	s.where = append(s.where, conds...)
+	return s
+}
+
+// AllowAll 允许没有 WHERE 条件，否则 Build 返回错误，防止忘记条件删除整个表
+func (s *DeleteBuilder) AllowAll() *DeleteBuilder {
+	s.allowAll = true
+	return s
+}
+
+func (s *DeleteBuilder) Build() (string, []interface{}, error) {
+	w := &sqlWriter{dialect: s.dialect}
+	if len(s.where) == 0 && !s.allowAll {
+		w.fail("delete without where, call AllowAll to delete all rows")
+	}
+	w.write("DELETE FROM ")
+	w.table(s.table)
+	writeWhere(w, "WHERE", s.where)
+	return w.result()
+}
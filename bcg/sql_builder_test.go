@@ -0,0 +1,56 @@
+package bcg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInsertBuilderErrors(t *testing.T) {
+	dialects := []SqlDialect{DialectMysql, DialectSqlite, DialectPostgres}
+	cases := []struct {
+		name  string
+		build func(b SqlBuilder) *InsertBuilder
+		want  string
+	}{
+		{"no columns do nothing", func(b SqlBuilder) *InsertBuilder {
+			return b.InsertInto("t").DoNothing()
+		}, "insert without columns"},
+		{"empty row do nothing", func(b SqlBuilder) *InsertBuilder {
+			return b.InsertInto("t").Row(map[string]interface{}{}).DoNothing()
+		}, "insert without columns"},
+		{"no columns do update", func(b SqlBuilder) *InsertBuilder {
+			return b.InsertInto("t").OnConflict("id").DoUpdate("name")
+		}, "insert without columns"},
+		{"row length mismatch", func(b SqlBuilder) *InsertBuilder {
+			return b.InsertInto("t").Columns("id", "name").Values(1).DoNothing()
+		}, "row 0 has 1 values"},
+	}
+	for _, d := range dialects {
+		for _, c := range cases {
+			sqlCase, args, err := c.build(NewSqlBuilder(d)).Build()
+			if err == nil || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("dialect %d %s: err = %v, want %q", d, c.name, err, c.want)
+			}
+			if sqlCase != "" || args != nil {
+				t.Errorf("dialect %d %s: got %q %v with error", d, c.name, sqlCase, args)
+			}
+		}
+	}
+}
+
+func TestInsertBuilderDoNothing(t *testing.T) {
+	cases := []struct {
+		dialect SqlDialect
+		want    string
+	}{
+		{DialectMysql, "INSERT INTO `t` (`id`) VALUES (?) ON DUPLICATE KEY UPDATE `id`=`id`"},
+		{DialectSqlite, `INSERT INTO "t" ("id") VALUES (?) ON CONFLICT DO NOTHING`},
+		{DialectPostgres, `INSERT INTO "t" ("id") VALUES ($1) ON CONFLICT DO NOTHING`},
+	}
+	for _, c := range cases {
+		sqlCase, _, err := NewSqlBuilder(c.dialect).InsertInto("t").Columns("id").Values(1).DoNothing().Build()
+		if err != nil || sqlCase != c.want {
+			t.Errorf("dialect %d: got %q %v, want %q", c.dialect, sqlCase, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package bcg
+
+// sql_db 多个命名的数据库连接，比如同时使用游戏库、账号库和日志库：
+//
+//	bcg.OpenMysqlDb("accounts", user, pass, host, "accounts")
+//	bcg.Use("accounts").Query("SELECT id,name FROM user WHERE id=?", func(rows *sql.Rows) {...}, id)
+//
+// 名字为 DefaultDbName 的连接就是 OpenMysql 打开的连接，Query、Exec 等函数使用的都是它。
+// 每个连接可以设置默认超时时间，Query 和 Exec 超时后返回 context.DeadlineExceeded，
+// 正在读取的 rows.Next 会返回 false，所以回调函数中的循环会提前结束，不需要特别处理。
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDbName 默认连接的名字
+const DefaultDbName = "default"
+
+var (
+	dbsLock sync.RWMutex
+	dbs     = map[string]*SqlDb{}
+	// dbTimeouts 按名字保存的超时时间，在注册之前设置或者重新注册时都不会丢失
+	dbTimeouts = map[string]time.Duration{}
+)
+
+// SqlDb 命名的数据库连接，Query 和 Exec 的用法和同名的包函数相同
+type SqlDb struct {
+	name    string
+	db      *sql.DB
+	timeout int64
+}
+
+// RegisterDb 注册一个已经打开的连接，同名的连接会被替换（不会被关闭），name 为 DefaultDbName 时替换默认连接。
+// 之前用这个名字设置的超时时间对新的连接同样有效
+func RegisterDb(name string, h *sql.DB) *SqlDb {
+	d := &SqlDb{name: name, db: h}
+	dbsLock.Lock()
+	d.timeout = int64(dbTimeouts[name])
+	dbs[name] = d
+	dbsLock.Unlock()
+	return d
+}
+
+// Use 返回名字为 name 的连接，没有注册的连接也会返回一个 SqlDb，调用它的函数时返回错误
+func Use(name string) *SqlDb {
+	dbsLock.RLock()
+	defer dbsLock.RUnlock()
+	if d, ok := dbs[name]; ok {
+		return d
+	}
+	return &SqlDb{name: name}
+}
+
+// DbNames 返回所有注册的连接的名字，默认连接打开后也包括在内
+func DbNames() []string {
+	dbsLock.RLock()
+	defer dbsLock.RUnlock()
+	names := make([]string, 0, len(dbs))
+	for name := range dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CloseDb 关闭并删除名字为 name 的连接
+func CloseDb(name string) error {
+	dbsLock.Lock()
+	var h *sql.DB
+	if d, ok := dbs[name]; ok {
+		h = d.db
+		delete(dbs, name)
+	}
+	dbsLock.Unlock()
+	if h == nil {
+		return fmt.Errorf("database %q is not registered", name)
+	}
+	return h.Close()
+}
+
+// OpenMysqlDb 打开 Mysql 连接并用 name 注册
+func OpenMysqlDb(name, user, pass, host, dbname string) (*SqlDb, error) {
+	h, err := sql.Open("mysql", mysqlDsn(user, pass, host, dbname))
+	if err != nil {
+		return nil, err
+	}
+	return RegisterDb(name, h), nil
+}
+
+// OpenSqliteDb 打开 Sqlite 连接并用 name 注册，path 和 dbname 见 OpenSqlite
+func OpenSqliteDb(name, path, dbname string) (*SqlDb, error) {
+	h, err := OpenSqlite(path, dbname)
+	if err != nil {
+		return nil, err
+	}
+	return RegisterDb(name, h), nil
+}
+
+func (d *SqlDb) Name() string {
+	return d.name
+}
+
+// DB 返回底层的 *sql.DB，连接没有注册时返回 nil
+func (d *SqlDb) DB() *sql.DB {
+	return d.db
+}
+
+// SetTimeout 设置这个连接的 Query 和 Exec 的默认超时时间，0 表示不限制。
+// 传入的 context 有更早的截止时间时使用 context 的截止时间。超时时间按名字保存，
+// 所以可以在连接注册之前设置，重新注册同名的连接后也仍然有效
+func (d *SqlDb) SetTimeout(timeout time.Duration) {
+	dbsLock.Lock()
+	dbTimeouts[d.name] = timeout
+	if cur, ok := dbs[d.name]; ok && cur != d {
+		atomic.StoreInt64(&cur.timeout, int64(timeout))
+	}
+	dbsLock.Unlock()
+	atomic.StoreInt64(&d.timeout, int64(timeout))
+}
+
+func (d *SqlDb) Timeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&d.timeout))
+}
+
+func (d *SqlDb) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := d.Timeout(); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func (d *SqlDb) handle() (*sql.DB, error) {
+	if d.db == nil {
+		return nil, fmt.Errorf("database %q is not registered", d.name)
+	}
+	return d.db, nil
+}
+
+// Query 见包函数 Query，回调函数返回后 rows 会被自动关闭
+func (d *SqlDb) Query(sqlCase string, qc QueryCall, v ...interface{}) error {
+	return d.query(context.Background(), 3, sqlCase, qc, v...)
+}
+
+// QueryContext ctx 被取消或者超时后 rows.Next 返回 false，回调函数返回后这个函数返回 ctx 的错误
+func (d *SqlDb) QueryContext(ctx context.Context, sqlCase string, qc QueryCall, v ...interface{}) error {
+	return d.query(ctx, 3, sqlCase, qc, v...)
+}
+
+// query trace 是输出错误时跳过的调用层数，使错误显示调用 Query 的位置
+func (d *SqlDb) query(ctx context.Context, trace uint, sqlCase string, qc QueryCall, v ...interface{}) error {
+	h, err := d.handle()
+	if CheckErrTrace(err, trace) {
+		return err
+	}
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	return queryRows(ctx, h, trace+1, sqlCase, qc, v...)
+}
+
+// sqlQueryer *sql.DB 和 *sql.Tx 共同的接口
+type sqlQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// queryRows 执行查询并调用回调函数，读取数据的过程中出错（包括超时）时返回 rows.Err()
+func queryRows(ctx context.Context, q sqlQueryer, trace uint, sqlCase string, qc QueryCall, v ...interface{}) error {
+	rows, err := q.QueryContext(ctx, sqlCase, v...)
+	if CheckErrTrace(err, trace) {
+		return err
+	}
+	qc(rows)
+	err = rows.Err()
+	_ = rows.Close()
+	if CheckErrTrace(err, trace) {
+		return err
+	}
+	return nil
+}
+
+func (d *SqlDb) Exec(sqlCase string, v ...interface{}) (sql.Result, error) {
+	return d.ExecContext(context.Background(), sqlCase, v...)
+}
+
+func (d *SqlDb) ExecContext(ctx context.Context, sqlCase string, v ...interface{}) (sql.Result, error) {
+	h, err := d.handle()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+	return h.ExecContext(ctx, sqlCase, v...)
+}
@@ -0,0 +1,203 @@
+package bcg
+
+// sql_json 把查询结果转换为 JsonArray，每一行是一个以列名为 key 的 JsonObject，用于管理接口直接输出数据。
+// 类型转换规则：
+//   - []byte 转换为字串。Mysql 的文本协议（没有参数的查询）所有的值都是 []byte，
+//     这时根据列的类型把整数列转换为 int64，浮点数列转换为 float64，DECIMAL 转换为 json.Number，保持精度
+//   - 整数保持为 int64，不会转换为 float64，所以大的 id 不会丢失精度
+//   - 时间转换为 FormatDateTime 格式的字串
+//   - NULL 转换为 nil
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type QueryJsonOptions struct {
+	// Rename 列名到 key 的对应关系，值为 "-" 时忽略这一列
+	Rename map[string]string
+	// KeyFunc 转换没有在 Rename 中列出的列名，为 nil 时使用列名
+	KeyFunc func(col string) string
+	// TimeFormat 时间的格式，为空时使用 FormatDateTime
+	TimeFormat string
+}
+
+// QueryJson 查询并返回所有的行，q 为 nil 时使用默认连接
+func QueryJson(q SqlQuerier, sqlCase string, v ...interface{}) (JsonArray, error) {
+	return QueryJsonWith(q, nil, sqlCase, v...)
+}
+
+// QueryJsonWith 和 QueryJson 相同，opt 可以为 nil
+func QueryJsonWith(q SqlQuerier, opt *QueryJsonOptions, sqlCase string, v ...interface{}) (JsonArray, error) {
+	if q == nil {
+		q = Use(DefaultDbName)
+	}
+	var result JsonArray
+	var scanErr error
+	err := q.Query(sqlCase, func(rows *sql.Rows) {
+		result, scanErr = ScanJsonRows(rows, opt)
+	}, v...)
+	if err != nil {
+		return nil, err
+	}
+	return result, scanErr
+}
+
+// QueryJsonObject 查询并返回第一行，没有数据时返回 sql.ErrNoRows
+func QueryJsonObject(q SqlQuerier, opt *QueryJsonOptions, sqlCase string, v ...interface{}) (JsonObject, error) {
+	if q == nil {
+		q = Use(DefaultDbName)
+	}
+	var js JsonObject
+	scanErr := sql.ErrNoRows
+	err := q.Query(sqlCase, func(rows *sql.Rows) {
+		s, err := newJsonRowScanner(rows, opt)
+		if err != nil {
+			scanErr = err
+			return
+		}
+		if rows.Next() {
+			js, scanErr = s.scan(rows)
+		}
+	}, v...)
+	if err != nil {
+		return nil, err
+	}
+	return js, scanErr
+}
+
+// ScanJsonRows 在 Query 的回调函数中使用，读取所有的行，没有数据时返回空数组
+func ScanJsonRows(rows *sql.Rows, opt *QueryJsonOptions) (JsonArray, error) {
+	result := NewJsonArray()
+	s, err := newJsonRowScanner(rows, opt)
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		js, err := s.scan(rows)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, js)
+	}
+	return result, nil
+}
+
+// jsonColumnKind 根据列的类型决定 []byte 的转换方式
+const (
+	jsonColumnString = iota
+	jsonColumnInt
+	jsonColumnFloat
+	jsonColumnDecimal
+)
+
+type jsonRowScanner struct {
+	keys       []string
+	kinds      []int
+	timeFormat string
+}
+
+func newJsonRowScanner(rows *sql.Rows, opt *QueryJsonOptions) (*jsonRowScanner, error) {
+	if opt == nil {
+		opt = &QueryJsonOptions{}
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	s := &jsonRowScanner{keys: make([]string, len(cols)), kinds: make([]int, len(cols)), timeFormat: opt.TimeFormat}
+	if s.timeFormat == "" {
+		s.timeFormat = FormatDateTime
+	}
+	for i, col := range cols {
+		key, ok := opt.Rename[col]
+		if !ok {
+			key = col
+			if opt.KeyFunc != nil {
+				key = opt.KeyFunc(col)
+			}
+		}
+		s.keys[i] = key
+	}
+	// 有些驱动不支持列类型，这时 []byte 都转换为字串
+	if types, err := rows.ColumnTypes(); err == nil {
+		for i, t := range types {
+			s.kinds[i] = jsonColumnKind(t.DatabaseTypeName())
+		}
+	}
+	return s, nil
+}
+
+// jsonIntTypes 整数列的类型名，Mysql 返回 INT、UNSIGNED BIGINT 等，Sqlite 返回建表时声明的类型，PostgreSQL 返回 INT2、INT4、INT8
+var jsonIntTypes = map[string]bool{
+	"INT": true, "INTEGER": true, "BIGINT": true, "TINYINT": true, "SMALLINT": true, "MEDIUMINT": true,
+	"INT2": true, "INT4": true, "INT8": true,
+}
+
+// jsonColumnKind 按类型名精确匹配，忽略长度（比如 INT(11)）和 UNSIGNED，POINT、INTERVAL 等类型作为字串
+func jsonColumnKind(typeName string) int {
+	name := strings.ToUpper(typeName)
+	if i, j := strings.IndexByte(name, '('), strings.IndexByte(name, ')'); i >= 0 && j > i {
+		name = name[:i] + name[j+1:]
+	}
+	name = strings.TrimSpace(strings.ReplaceAll(name, "UNSIGNED", ""))
+	switch {
+	case jsonIntTypes[name]:
+		return jsonColumnInt
+	case name == "FLOAT" || name == "DOUBLE" || name == "REAL":
+		return jsonColumnFloat
+	case name == "DECIMAL" || name == "NUMERIC":
+		return jsonColumnDecimal
+	}
+	return jsonColumnString
+}
+
+func (s *jsonRowScanner) scan(rows *sql.Rows) (JsonObject, error) {
+	values := make([]interface{}, len(s.keys))
+	targets := make([]interface{}, len(s.keys))
+	for i := range values {
+		targets[i] = &values[i]
+	}
+	if err := rows.Scan(targets...); err != nil {
+		return nil, err
+	}
+	js := make(JsonObject, len(s.keys))
+	for i, key := range s.keys {
+		if key == "-" {
+			continue
+		}
+		js[key] = s.convert(values[i], s.kinds[i])
+	}
+	return js, nil
+}
+
+func (s *jsonRowScanner) convert(v interface{}, kind int) interface{} {
+	switch x := v.(type) {
+	case []byte:
+		str := string(x)
+		switch kind {
+		case jsonColumnInt:
+			if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+				return i
+			}
+			if u, err := strconv.ParseUint(str, 10, 64); err == nil {
+				return u
+			}
+		case jsonColumnFloat:
+			if f, err := strconv.ParseFloat(str, 64); err == nil {
+				return f
+			}
+		case jsonColumnDecimal:
+			if _, err := strconv.ParseFloat(str, 64); err == nil {
+				return json.Number(str)
+			}
+		}
+		return str
+	case time.Time:
+		return x.Format(s.timeFormat)
+	}
+	return v
+}
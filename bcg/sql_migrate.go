@@ -0,0 +1,416 @@
+package bcg
+
+// sql_migrate 按版本执行数据库迁移，替代每个项目自己写的 CREATE TABLE IF NOT EXISTS。
+// 迁移文件放在一个目录或者 embed.FS 中，文件名为 版本_名字.up.sql 和 版本_名字.down.sql，版本是正整数，
+// 比如 0001_create_user.up.sql，down 文件可以没有，没有时这个版本不能回滚：
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	sub, _ := fs.Sub(migrations, "migrations")
+//	n, err := bcg.NewMigrator(nil, bcg.DialectMysql, sub).Up()
+//
+// 目录使用 os.DirFS(dir)。已经执行的版本、名字和 up、down 文件的 sha256 保存在 schema_migrations 表中，
+// 执行前会检查所有已经执行的文件，文件被修改或者删除时拒绝执行，已经执行的迁移应该用新的迁移修改。
+// 比最后执行的版本小的新文件（比如合并分支后）也会拒绝执行，需要改为更大的版本号。
+//
+// 每个迁移在一个事务中执行，失败时回滚，不会记录版本。注意 Mysql 的 DDL 语句会隐式提交事务，
+// 所以 Mysql 的迁移失败时已经执行的 DDL 不会回滚，每个迁移最好只包含一个 DDL 语句。
+// 文件中的语句按引号和注释以外的 ; 分开后逐个执行，所以 Mysql 连接不需要 multiStatements。
+// 文件中可以使用以下指令，每个指令单独一行：
+//
+//	-- migrate:notx     不使用事务执行，比如 PostgreSQL 的 CREATE INDEX CONCURRENTLY
+//	-- migrate:nosplit  整个文件作为一个语句执行，比如包含 ; 的触发器
+//
+// 多个进程同时执行迁移时不会互相等待，后执行的进程写入版本记录时会因为主键冲突而失败。
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMigrationTable 保存迁移记录的表名
+const DefaultMigrationTable = "schema_migrations"
+
+// Migration 一个版本的迁移文件
+type Migration struct {
+	Version int64
+	Name    string
+	// UpFile DownFile 文件名，DownFile 为空表示不能回滚
+	UpFile   string
+	DownFile string
+	// Checksum up 和 down 文件内容的 sha256，见 checksum
+	Checksum string
+}
+
+// MigrationStatus Status 返回的每个版本的状态，AppliedAt 为空表示还没有执行
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt string
+}
+
+type Migrator struct {
+	db      *SqlDb
+	dialect SqlDialect
+	fsys    fs.FS
+	// Table 保存迁移记录的表名，默认为 DefaultMigrationTable
+	Table string
+}
+
+type migrationRecord struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt string
+}
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_([^.]+)\.(up|down)\.sql$`)
+
+// NewMigrator d 为 nil 时使用默认连接，dialect 决定记录表的语句格式和 ; 的分割方式
+func NewMigrator(d *SqlDb, dialect SqlDialect, fsys fs.FS) *Migrator {
+	if d == nil {
+		d = Use(DefaultDbName)
+	}
+	return &Migrator{db: d, dialect: dialect, fsys: fsys, Table: DefaultMigrationTable}
+}
+
+// Migrations 读取所有的迁移文件，按版本排序
+func (m *Migrator) Migrations() ([]*Migration, error) {
+	entries, err := fs.ReadDir(m.fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		match := migrationFileRegexp.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", e.Name())
+		}
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mg
+		} else if mg.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has different names %q and %q", version, mg.Name, match[2])
+		}
+		if match[3] == "up" {
+			mg.UpFile = e.Name()
+		} else {
+			mg.DownFile = e.Name()
+		}
+	}
+	list := make([]*Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.UpFile == "" {
+			return nil, fmt.Errorf("migration %d %s has no up file", mg.Version, mg.Name)
+		}
+		if mg.Checksum, err = m.checksum(mg); err != nil {
+			return nil, err
+		}
+		list = append(list, mg)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// checksum 没有 down 文件时是 up 文件内容的 sha256，否则是两个文件内容的 sha256 连接后的 sha256，
+// 所以修改、增加或者删除已经执行的版本的 down 文件同样会被发现
+func (m *Migrator) checksum(mg *Migration) (string, error) {
+	up, err := fs.ReadFile(m.fsys, mg.UpFile)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(up)
+	if mg.DownFile != "" {
+		down, err := fs.ReadFile(m.fsys, mg.DownFile)
+		if err != nil {
+			return "", err
+		}
+		downSum := sha256.Sum256(down)
+		sum = sha256.Sum256(append(sum[:], downSum[:]...))
+	}
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Up 执行所有没有执行的迁移，返回执行的数量
+func (m *Migrator) Up() (int, error) {
+	return m.UpTo(0)
+}
+
+// UpTo 执行版本不大于 version 的迁移，version 为 0 时执行所有迁移
+func (m *Migrator) UpTo(version int64) (int, error) {
+	list, applied, err := m.prepare()
+	if err != nil {
+		return 0, err
+	}
+	var last int64
+	for v := range applied {
+		if v > last {
+			last = v
+		}
+	}
+	n := 0
+	for _, mg := range list {
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+		if version > 0 && mg.Version > version {
+			break
+		}
+		if mg.Version < last {
+			return n, fmt.Errorf("migration %d %s is older than applied version %d", mg.Version, mg.Name, last)
+		}
+		if err = m.run(mg, true); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Down 按版本从大到小回滚 steps 个已经执行的迁移，返回回滚的数量
+func (m *Migrator) Down(steps int) (int, error) {
+	list, applied, err := m.prepare()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i := len(list) - 1; i >= 0 && n < steps; i-- {
+		mg := list[i]
+		if _, ok := applied[mg.Version]; !ok {
+			continue
+		}
+		if mg.DownFile == "" {
+			return n, fmt.Errorf("migration %d %s has no down file", mg.Version, mg.Name)
+		}
+		if err = m.run(mg, false); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Status 返回所有迁移文件的状态，同样会检查已经执行的文件是否被修改
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	list, applied, err := m.prepare()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]MigrationStatus, 0, len(list))
+	for _, mg := range list {
+		status := MigrationStatus{Version: mg.Version, Name: mg.Name}
+		if r, ok := applied[mg.Version]; ok {
+			status.AppliedAt = r.AppliedAt
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+// Version 返回最后执行的版本，没有执行过时返回 0
+func (m *Migrator) Version() (int64, error) {
+	if err := m.createTable(); err != nil {
+		return 0, err
+	}
+	sqlCase, args, err := NewSqlBuilder(m.dialect).Select().ColumnExpr("COALESCE(MAX(version), 0)").From(m.Table).Build()
+	if err != nil {
+		return 0, err
+	}
+	return QueryOne[int64](m.db, sqlCase, args...)
+}
+
+// prepare 读取迁移文件和已经执行的记录，并检查已经执行的文件没有被修改
+func (m *Migrator) prepare() ([]*Migration, map[int64]migrationRecord, error) {
+	list, err := m.Migrations()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = m.createTable(); err != nil {
+		return nil, nil, err
+	}
+	sqlCase, args, err := NewSqlBuilder(m.dialect).Select("version", "name", "checksum", "applied_at").From(m.Table).Build()
+	if err != nil {
+		return nil, nil, err
+	}
+	records, err := QueryAll[migrationRecord](m.db, sqlCase, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	byVersion := make(map[int64]*Migration, len(list))
+	for _, mg := range list {
+		byVersion[mg.Version] = mg
+	}
+	applied := make(map[int64]migrationRecord, len(records))
+	for _, r := range records {
+		mg, ok := byVersion[r.Version]
+		if !ok {
+			return nil, nil, fmt.Errorf("applied migration %d %s is missing", r.Version, r.Name)
+		}
+		if mg.Checksum != r.Checksum {
+			return nil, nil, fmt.Errorf("applied migration %d %s has been changed (up or down file), checksum %s, expected %s",
+				r.Version, mg.Name, mg.Checksum, r.Checksum)
+		}
+		applied[r.Version] = r
+	}
+	return list, applied, nil
+}
+
+func (m *Migrator) createTable() error {
+	w := &sqlWriter{dialect: m.dialect}
+	w.write("CREATE TABLE IF NOT EXISTS ")
+	w.ident(m.Table, false)
+	w.write(" (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, " +
+		"checksum VARCHAR(64) NOT NULL, applied_at VARCHAR(32) NOT NULL)")
+	sqlCase, _, err := w.result()
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(sqlCase)
+	return err
+}
+
+// migrationTxOptions 迁移只执行一次，不重试：Mysql 的 DDL 会隐式提交，重试会再次执行已经生效的 DDL
+var migrationTxOptions = TxOptions{MaxRetries: 0, Retryable: func(error) bool { return false }}
+
+// run 执行一个迁移文件，并在同一个事务中写入或者删除版本记录
+func (m *Migrator) run(mg *Migration, up bool) error {
+	file := mg.UpFile
+	if !up {
+		file = mg.DownFile
+	}
+	content, err := fs.ReadFile(m.fsys, file)
+	if err != nil {
+		return err
+	}
+	stmts, noTx := parseMigration(string(content), m.dialect)
+	b := NewSqlBuilder(m.dialect)
+	var recordSql string
+	var recordArgs []interface{}
+	if up {
+		recordSql, recordArgs, err = b.InsertInto(m.Table).Columns("version", "name", "checksum", "applied_at").
+			Values(mg.Version, mg.Name, mg.Checksum, time.Now().Format(FormatDateTime)).Build()
+	} else {
+		recordSql, recordArgs, err = b.DeleteFrom(m.Table).Where(SqlEq("version", mg.Version)).Build()
+	}
+	if err != nil {
+		return err
+	}
+	exec := func(execFn func(string, ...interface{}) (sql.Result, error)) error {
+		for _, stmt := range stmts {
+			if _, err := execFn(stmt); err != nil {
+				return err
+			}
+		}
+		_, err := execFn(recordSql, recordArgs...)
+		return err
+	}
+	if noTx {
+		err = exec(m.db.Exec)
+	} else {
+		err = m.db.WithTxContext(context.Background(), &migrationTxOptions, func(tx *SqlTx) error {
+			return exec(tx.Exec)
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("migration %s: %v", file, err)
+	}
+	LogGreen("migration done:", file)
+	return nil
+}
+
+// parseMigration 读取文件中的指令并分割语句
+func parseMigration(content string, dialect SqlDialect) (stmts []string, noTx bool) {
+	noSplit := false
+	for _, line := range strings.Split(content, "\n") {
+		switch strings.TrimSpace(line) {
+		case "-- migrate:notx":
+			noTx = true
+		case "-- migrate:nosplit":
+			noSplit = true
+		}
+	}
+	if noSplit {
+		if s := strings.TrimSpace(content); s != "" {
+			stmts = append(stmts, s)
+		}
+		return stmts, noTx
+	}
+	return splitSqlStatements(content, dialect), noTx
+}
+
+// splitSqlStatements 按引号、-- 注释和 /* */ 注释以外的 ; 分割语句，只包含空白和注释的部分被忽略。
+// 只有 Mysql 的字串中 \ 是转义字符，Mysql 的 /*! */ 是会被执行的注释，作为语句的内容；
+// PostgreSQL 的 $$ 或者 $tag$ 引起的函数体中的 ; 不会分割语句
+func splitSqlStatements(content string, dialect SqlDialect) []string {
+	var stmts []string
+	start := 0
+	hasCode := false
+	add := func(end int) {
+		if hasCode {
+			stmts = append(stmts, strings.TrimSpace(content[start:end]))
+		}
+		start = end + 1
+		hasCode = false
+	}
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			hasCode = true
+			for i++; i < len(content) && content[i] != c; i++ {
+				if content[i] == '\\' && dialect == DialectMysql && c != '`' {
+					i++
+				}
+			}
+		case c == '$' && dialect == DialectPostgres && sqlDollarTagRegexp.MatchString(content[i:]):
+			hasCode = true
+			tag := sqlDollarTagRegexp.FindString(content[i:])
+			if j := strings.Index(content[i+len(tag):], tag); j >= 0 {
+				i += len(tag) + j + len(tag) - 1
+			} else {
+				i = len(content)
+			}
+		case c == '-' && strings.HasPrefix(content[i:], "--"):
+			if j := strings.IndexByte(content[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(content)
+			}
+		case c == '/' && strings.HasPrefix(content[i:], "/*"):
+			if dialect == DialectMysql && strings.HasPrefix(content[i:], "/*!") {
+				hasCode = true
+			}
+			if j := strings.Index(content[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(content)
+			}
+		case c == ';':
+			add(i)
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			hasCode = true
+		}
+	}
+	if start < len(content) {
+		add(len(content))
+	}
+	return stmts
+}
+
+// sqlDollarTagRegexp PostgreSQL 的 $$ 或者 $tag$，不包括 $1 这样的参数
+var sqlDollarTagRegexp = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
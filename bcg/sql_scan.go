@@ -0,0 +1,265 @@
+package bcg
+
+// sql_scan 把查询结果转换为结构体、结构体切片或者单个值，基于 Query 实现，rows 同样会被自动关闭：
+//
+//	type User struct {
+//		Id      int64
+//		Name    string    `db:"nick_name"`
+//		Created time.Time `db:"create_time"`
+//		Extra   string    `db:"-"`
+//	}
+//	users, err := bcg.QueryAll[User](nil, "SELECT * FROM user WHERE level>?", 10)
+//	count, err := bcg.QueryOne[int](bcg.Use("accounts"), "SELECT COUNT(*) FROM user")
+//
+// 列名和字段的对应规则：字段有 `db:"name"` 标签时使用标签，`db:"-"` 的字段被忽略；没有标签时使用字段名，
+// 比较时忽略大小写和下划线，所以 UserId 可以对应 user_id。匿名嵌入的结构体的字段和外层字段一样处理，
+// 同名时外层的字段优先。没有对应字段的列会被忽略。
+// NULL 对于指针字段设置为 nil，对于其它字段设置为零值。time.Time 字段除了驱动返回的 time.Time 之外，
+// 还接受 FormatDateTime、FormatDate 和 RFC 3339 格式的字串，所以 Mysql 连接不需要设置 parseTime。
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SqlQuerier SqlDb 和 SqlTx 共同的接口，QueryAll 等函数可以在事务中使用
+type SqlQuerier interface {
+	Query(sqlCase string, qc QueryCall, v ...interface{}) error
+}
+
+// QueryAll 查询并把所有的行转换为 T，T 是结构体时按列名对应字段，否则查询结果必须只有一列。
+// q 为 nil 时使用默认连接。没有数据时返回空切片
+func QueryAll[T any](q SqlQuerier, sqlCase string, v ...interface{}) ([]T, error) {
+	if q == nil {
+		q = Use(DefaultDbName)
+	}
+	var result []T
+	var scanErr error
+	err := q.Query(sqlCase, func(rows *sql.Rows) {
+		result, scanErr = ScanRows[T](rows)
+	}, v...)
+	if err != nil {
+		return nil, err
+	}
+	return result, scanErr
+}
+
+// QueryOne 查询并把第一行转换为 T，没有数据时返回 sql.ErrNoRows
+func QueryOne[T any](q SqlQuerier, sqlCase string, v ...interface{}) (T, error) {
+	if q == nil {
+		q = Use(DefaultDbName)
+	}
+	var t T
+	scanErr := sql.ErrNoRows
+	err := q.Query(sqlCase, func(rows *sql.Rows) {
+		if rows.Next() {
+			scanErr = ScanRow(rows, &t)
+		}
+	}, v...)
+	if err != nil {
+		return t, err
+	}
+	return t, scanErr
+}
+
+// ScanRows 在 Query 的回调函数中使用，读取所有的行，出错时停止读取
+func ScanRows[T any](rows *sql.Rows) ([]T, error) {
+	result := make([]T, 0)
+	for rows.Next() {
+		var t T
+		if err := ScanRow(rows, &t); err != nil {
+			return result, err
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// ScanRow 把当前行转换后保存到 dst，dst 必须是指针，需要先调用 rows.Next
+func ScanRow(rows *sql.Rows, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("scan: destination must be a non-nil pointer")
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	elem := rv.Elem()
+	targets := make([]interface{}, len(cols))
+	var afters []func()
+	if !isScanStruct(elem.Type()) {
+		if len(cols) != 1 {
+			return fmt.Errorf("scan: %d columns into %s, expected 1 column", len(cols), elem.Type())
+		}
+		targets[0], afters = scanTarget(elem, afters)
+	} else {
+		fields := structColumns(elem.Type())
+		for i, col := range cols {
+			index, ok := fields[columnKey(col)]
+			if !ok {
+				targets[i] = new(interface{})
+				continue
+			}
+			targets[i], afters = scanTarget(fieldByIndexAlloc(elem, index), afters)
+		}
+	}
+	if err = rows.Scan(targets...); err != nil {
+		return fmt.Errorf("scan %s: %v", elem.Type(), err)
+	}
+	for _, after := range afters {
+		after()
+	}
+	return nil
+}
+
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// isScanStruct 结构体按列名对应字段，time.Time 和实现了 sql.Scanner 的结构体（比如 sql.NullString）作为单个值
+func isScanStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(scannerType)
+}
+
+// scanTarget 返回传给 rows.Scan 的参数，需要在 Scan 之后处理的操作加入 afters
+func scanTarget(v reflect.Value, afters []func()) (interface{}, []func()) {
+	t := v.Type()
+	switch {
+	case t == timeType || (t.Kind() == reflect.Ptr && t.Elem() == timeType):
+		return &timeScanner{v: v}, afters
+	case reflect.PtrTo(t).Implements(scannerType), t.Kind() == reflect.Ptr:
+		// 指针字段由 database/sql 处理，NULL 时设置为 nil
+		return v.Addr().Interface(), afters
+	}
+	// 其它字段先扫描到指针，类型转换使用 database/sql 的规则，NULL 时设置为零值
+	p := reflect.New(reflect.PtrTo(t))
+	return p.Interface(), append(afters, func() {
+		if p.Elem().IsNil() {
+			v.Set(reflect.Zero(t))
+		} else {
+			v.Set(p.Elem().Elem())
+		}
+	})
+}
+
+// timeScanner 处理 time.Time 和 *time.Time 字段
+type timeScanner struct {
+	v reflect.Value
+}
+
+func (s *timeScanner) Scan(src interface{}) error {
+	isPtr := s.v.Kind() == reflect.Ptr
+	if src == nil {
+		s.v.Set(reflect.Zero(s.v.Type()))
+		return nil
+	}
+	var tm time.Time
+	switch x := src.(type) {
+	case time.Time:
+		tm = x
+	case []byte:
+		t, err := parseDbTime(string(x))
+		if err != nil {
+			return err
+		}
+		tm = t
+	case string:
+		t, err := parseDbTime(x)
+		if err != nil {
+			return err
+		}
+		tm = t
+	case int64:
+		tm = time.Unix(x, 0)
+	default:
+		return fmt.Errorf("unsupported scan, storing %T into time.Time", src)
+	}
+	if isPtr {
+		s.v.Set(reflect.ValueOf(&tm))
+	} else {
+		s.v.Set(reflect.ValueOf(tm))
+	}
+	return nil
+}
+
+// parseDbTime 解析数据库返回的时间字串，没有时区的时间使用本地时区
+func parseDbTime(s string) (time.Time, error) {
+	if s == "" || strings.HasPrefix(s, "0000-00-00") {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{FormatDateTime + ".999999999", time.RFC3339Nano, FormatDate} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as time", s)
+}
+
+var structColumnsCache sync.Map
+
+// structColumns 返回列名（见 columnKey）到字段下标的对应关系，结果按类型缓存
+func structColumns(t reflect.Type) map[string][]int {
+	if m, ok := structColumnsCache.Load(t); ok {
+		return m.(map[string][]int)
+	}
+	m := map[string][]int{}
+	depth := map[string]int{}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("db")
+			if tag == "-" {
+				continue
+			}
+			idx := append(append([]int{}, index...), i)
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			// 未导出的嵌入结构体的导出字段和 encoding/json 一样展开，但是未导出的嵌入指针为 nil 时不能分配，所以忽略
+			if f.Anonymous && tag == "" && isScanStruct(ft) && (f.IsExported() || f.Type.Kind() != reflect.Ptr) {
+				walk(ft, idx)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			name := tag
+			if name == "" {
+				name = f.Name
+			}
+			key := columnKey(name)
+			if d, ok := depth[key]; ok && d <= len(idx) {
+				continue
+			}
+			m[key] = idx
+			depth[key] = len(idx)
+		}
+	}
+	walk(t, nil)
+	structColumnsCache.Store(t, m)
+	return m
+}
+
+// columnKey 比较列名和字段名时忽略大小写和下划线
+func columnKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// fieldByIndexAlloc 和 reflect.Value.FieldByIndex 相同，但是会为空的嵌入指针分配内存
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
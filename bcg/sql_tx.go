@@ -0,0 +1,157 @@
+package bcg
+
+// sql_tx 事务辅助函数，fn 返回 nil 时提交，返回错误或者 panic 时回滚：
+//
+//	err := bcg.WithTx(func(tx *bcg.SqlTx) error {
+//		if _, err := tx.Exec("UPDATE bag SET gold=gold-? WHERE uid=?", price, uid); err != nil {
+//			return err
+//		}
+//		_, err := tx.Exec("INSERT INTO item (uid,item) VALUES (?,?)", uid, item)
+//		return err
+//	})
+//
+// 遇到 Mysql 死锁（1213）、锁等待超时（1205）或者 Sqlite 的 SQLITE_BUSY 时，回滚后等待一段时间重新执行整个 fn，
+// 所以 fn 除了数据库操作之外不应该有其它副作用。
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+type TxOptions struct {
+	// Isolation 隔离级别，sql.LevelDefault 表示使用数据库的默认级别
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// MaxRetries 最多重试的次数，0 表示不重试
+	MaxRetries int
+	// Backoff 第一次重试前等待的时间，之后每次加倍，最多等待 MaxBackoff，实际等待时间会加上随机的一半，避免再次冲突
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable 判断错误是否可以重试，为 nil 时使用 IsRetryableTxError
+	Retryable func(err error) bool
+}
+
+// DefaultTxOptions WithTx 使用的选项，opt 为 nil 时也使用它
+var DefaultTxOptions = TxOptions{MaxRetries: 3, Backoff: 20 * time.Millisecond, MaxBackoff: time.Second}
+
+// SqlTx 事务中的 Query 和 Exec，用法和 SqlDb 相同，连接的超时时间对每个语句有效
+type SqlTx struct {
+	tx  *sql.Tx
+	ctx context.Context
+	db  *SqlDb
+}
+
+// Tx 返回底层的 *sql.Tx，不要调用它的 Commit 和 Rollback
+func (t *SqlTx) Tx() *sql.Tx {
+	return t.tx
+}
+
+func (t *SqlTx) Query(sqlCase string, qc QueryCall, v ...interface{}) error {
+	ctx, cancel := t.db.withTimeout(t.ctx)
+	defer cancel()
+	return queryRows(ctx, t.tx, 3, sqlCase, qc, v...)
+}
+
+func (t *SqlTx) Exec(sqlCase string, v ...interface{}) (sql.Result, error) {
+	ctx, cancel := t.db.withTimeout(t.ctx)
+	defer cancel()
+	return t.tx.ExecContext(ctx, sqlCase, v...)
+}
+
+// IsRetryableTxError 判断是否是 Mysql 死锁（1213）、锁等待超时（1205）或者 Sqlite 数据库被锁（SQLITE_BUSY）的错误。
+// 为了不依赖数据库驱动，根据错误信息判断
+func IsRetryableTxError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, s := range []string{"Error 1213", "Error 1205", "database is locked", "SQLITE_BUSY"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithTx 在默认连接上执行事务
+func WithTx(fn func(tx *SqlTx) error) error {
+	return Use(DefaultDbName).withTx(context.Background(), 2, nil, fn)
+}
+
+func WithTxContext(ctx context.Context, opt *TxOptions, fn func(tx *SqlTx) error) error {
+	return Use(DefaultDbName).withTx(ctx, 2, opt, fn)
+}
+
+func (d *SqlDb) WithTx(fn func(tx *SqlTx) error) error {
+	return d.withTx(context.Background(), 2, nil, fn)
+}
+
+// WithTxContext 执行事务，opt 为 nil 时使用 DefaultTxOptions。可以重试的错误在重试次数用完后返回最后一次的错误，
+// ctx 被取消时不再重试
+func (d *SqlDb) WithTxContext(ctx context.Context, opt *TxOptions, fn func(tx *SqlTx) error) error {
+	return d.withTx(ctx, 2, opt, fn)
+}
+
+// withTx trace 是输出重试日志时跳过的调用层数，使日志显示调用 WithTx 的位置
+func (d *SqlDb) withTx(ctx context.Context, trace uint, opt *TxOptions, fn func(tx *SqlTx) error) error {
+	if opt == nil {
+		opt = &DefaultTxOptions
+	}
+	retryable := opt.Retryable
+	if retryable == nil {
+		retryable = IsRetryableTxError
+	}
+	h, err := d.handle()
+	if err != nil {
+		return err
+	}
+	backoff := opt.Backoff
+	for attempt := 0; ; attempt++ {
+		err = d.runTx(ctx, h, opt, fn)
+		if err == nil || attempt >= opt.MaxRetries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+		wait := backoff
+		if wait > 0 {
+			wait += time.Duration(rand.Int63n(int64(wait)/2 + 1))
+		}
+		LogTrace(TextYellow, trace, fmt.Sprintf("transaction retry %d/%d after %v:", attempt+1, opt.MaxRetries, wait), err.Error())
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+		if opt.MaxBackoff > 0 && backoff > opt.MaxBackoff {
+			backoff = opt.MaxBackoff
+		}
+	}
+}
+
+// runTx 执行一次事务，fn panic 时回滚并返回包含 panic 信息的错误
+func (d *SqlDb) runTx(ctx context.Context, h *sql.DB, opt *TxOptions, fn func(tx *SqlTx) error) (err error) {
+	tx, err := h.BeginTx(ctx, &sql.TxOptions{Isolation: opt.Isolation, ReadOnly: opt.ReadOnly})
+	if err != nil {
+		return err
+	}
+	done := false
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			LogRed("transaction panic:", r, string(debug.Stack()))
+			err = fmt.Errorf("transaction panic: %v", r)
+		} else if !done {
+			_ = tx.Rollback()
+		}
+	}()
+	if err = fn(&SqlTx{tx: tx, ctx: ctx, db: d}); err != nil {
+		return err
+	}
+	done = true
+	return tx.Commit()
+}